@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Account struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func getAccounts(c *gin.Context) {
+	rows, err := db.Query("SELECT id, name FROM accounts ORDER BY name")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.ID, &a.Name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		accounts = append(accounts, a)
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+func addAccount(c *gin.Context) {
+	var a Account
+	if err := c.ShouldBindJSON(&a); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO accounts (name) VALUES (?)", a.Name)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "account " + a.Name + " already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	a.ID = int(id)
+	c.JSON(http.StatusCreated, a)
+}
+
+// errUnknownAccount is wrapped by resolveAccounts when a row names an account
+// that doesn't exist.
+var errUnknownAccount = errors.New("unknown account")
+
+// resolveAccounts fills in AccountID for imported rows that name an account.
+// Unknown accounts are an error unless create is set, in which case they are
+// created inside tx alongside the import.
+func resolveAccounts(tx *sql.Tx, transactions []*Transaction, create bool) error {
+	ids := map[string]int{}
+	for _, t := range transactions {
+		name := strings.TrimSpace(t.Account)
+		if name == "" {
+			continue
+		}
+		id, ok := ids[name]
+		if !ok {
+			err := tx.QueryRow("SELECT id FROM accounts WHERE name = ?", name).Scan(&id)
+			if err == sql.ErrNoRows {
+				if !create {
+					return fmt.Errorf("%w %q (pass create_accounts=true to create it)", errUnknownAccount, name)
+				}
+				result, err := tx.Exec("INSERT INTO accounts (name) VALUES (?)", name)
+				if err != nil {
+					return err
+				}
+				newID, _ := result.LastInsertId()
+				id = int(newID)
+			} else if err != nil {
+				return err
+			}
+			ids[name] = id
+		}
+		t.AccountID = &id
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestAddTransactionUnknownAccount checks that a transaction can't point
+// at an account that doesn't exist.
+func TestAddTransactionUnknownAccount(t *testing.T) {
+	newTestDB(t)
+	if _, err := db.Exec("INSERT INTO accounts (name) VALUES ('Checking')"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		account string
+		want    int
+	}{
+		{"1", http.StatusCreated},
+		{"2", http.StatusUnprocessableEntity},
+		{"null", http.StatusCreated},
+	} {
+		body := `{"date": "2024-03-10T12:00:00Z", "amount": -5, "category": "Food", "type": "expense", "account_id": ` + tt.account + `}`
+		if w := serve(http.MethodPost, "/api/transactions", "/api/transactions", "application/json", body, addTransaction); w.Code != tt.want {
+			t.Errorf("account_id %s: got %d, want %d: %s", tt.account, w.Code, tt.want, w.Body)
+		}
+	}
+	var orphans int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions WHERE account_id IS NOT NULL AND account_id NOT IN (SELECT id FROM accounts)").Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("%d transactions reference a missing account", orphans)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// archiveTransactions moves transactions older than ?years= (default
+// ARCHIVE_AFTER_YEARS) into archived_transactions.
+func archiveTransactions(c *gin.Context) {
+	years := cfg.ArchiveAfterYears
+	if v := c.Query("years"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "years must be an integer"})
+			return
+		}
+		years = n
+	}
+	if years < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "years must be at least 1"})
+		return
+	}
+
+	archived, err := archiveOlderThan(years)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"archived": archived})
+}
+
+func archiveOlderThan(years int) (int64, error) {
+	cutoff := "-" + strconv.Itoa(years) + " years"
+	var archived int64
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(
+			"INSERT INTO archived_transactions SELECT * FROM transactions WHERE date(date) < date('now', ?)", cutoff,
+		); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM transactions WHERE date(date) < date('now', ?)", cutoff)
+		if err != nil {
+			return err
+		}
+		archived, err = result.RowsAffected()
+		return err
+	})
+	return archived, err
+}
+
+// normalizeSigns repairs amounts stored with the wrong sign, such as by
+// imports before expenses were negated: expenses become negative and income
+// and refunds positive. Transactions in closed periods are left alone and
+// counted as skipped.
+func normalizeSigns(c *gin.Context) {
+	const wrongSign = `
+		((type = ? AND amount_cents > 0) OR (type IN (?, ?) AND amount_cents < 0))
+		AND strftime('%Y-%m', datetime(date, ?)) `
+	args := []any{TypeExpense, TypeIncome, TypeRefund, tzModifier()}
+
+	var corrected, skipped int64
+	err := withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM transactions WHERE"+wrongSign+"IN (SELECT month FROM closed_periods)", args...,
+		).Scan(&skipped)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			"UPDATE transactions SET amount_cents = -amount_cents WHERE"+wrongSign+"NOT IN (SELECT month FROM closed_periods)", args...,
+		)
+		if err != nil {
+			return err
+		}
+		corrected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"corrected": corrected, "skipped_closed": skipped})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestArchiveAfterResetIDs checks that IDs handed out after a clear with
+// reset_ids=true don't collide with archived ones.
+func TestArchiveAfterResetIDs(t *testing.T) {
+	newTestDB(t)
+	old := time.Now().AddDate(-5, 0, 0)
+	mustInsert(t, Transaction{Date: old, Amount: -500, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: old, Amount: -700, Category: "Food", Type: TypeExpense})
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(http.MethodDelete, "/api/transactions", "/api/transactions?confirm=true&reset_ids=true", "", "", clearTransactions)
+	if w.Code != http.StatusOK {
+		t.Fatalf("clear: got %d: %s", w.Code, w.Body)
+	}
+
+	mustInsert(t, Transaction{Date: old, Amount: -900, Category: "Food", Type: TypeExpense})
+	var id int
+	if err := db.QueryRow("SELECT id FROM transactions").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Errorf("new transaction got id %d, want 3", id)
+	}
+	if n, err := archiveOlderThan(2); err != nil || n != 1 {
+		t.Errorf("archiving after reset: archived %d, %v; want 1, nil", n, err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AllocationRule sends Percent of every allocated paycheck to Bucket, such
+// as "Savings" or "Spending".
+type AllocationRule struct {
+	Bucket  string  `json:"bucket"`
+	Percent float64 `json:"percent"`
+}
+
+// Allocation is the share of an income transaction assigned to a bucket.
+type Allocation struct {
+	ID       int    `json:"id"`
+	IncomeID int    `json:"income_id"`
+	Bucket   string `json:"bucket"`
+	Amount   Money  `json:"amount"`
+}
+
+func getAllocationRules(c *gin.Context) {
+	rules, err := queryAllocationRules(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func queryAllocationRules(q queryer) ([]AllocationRule, error) {
+	rows, err := q.Query("SELECT bucket, percent FROM allocation_rules ORDER BY bucket")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AllocationRule{}
+	for rows.Next() {
+		var r AllocationRule
+		if err := rows.Scan(&r.Bucket, &r.Percent); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// setAllocationRules replaces every rule with the posted list, whose
+// percentages must add up to 100.
+func setAllocationRules(c *gin.Context) {
+	var rules []AllocationRule
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateAllocationRules(rules); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM allocation_rules"); err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if _, err := tx.Exec("INSERT INTO allocation_rules (bucket, percent) VALUES (?, ?)", r.Bucket, r.Percent); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func validateAllocationRules(rules []AllocationRule) error {
+	if len(rules) == 0 {
+		return errors.New("at least one rule is required")
+	}
+	seen := map[string]bool{}
+	total := 0.0
+	for i := range rules {
+		r := &rules[i]
+		r.Bucket = strings.TrimSpace(r.Bucket)
+		if r.Bucket == "" {
+			return errors.New("bucket is required")
+		}
+		if seen[r.Bucket] {
+			return fmt.Errorf("bucket %s is listed more than once", r.Bucket)
+		}
+		seen[r.Bucket] = true
+		if r.Percent <= 0 || r.Percent > 100 {
+			return fmt.Errorf("percent for %s must be greater than 0 and at most 100", r.Bucket)
+		}
+		total += r.Percent
+	}
+	if math.Abs(total-100) > 1e-9 {
+		return fmt.Errorf("percentages must sum to 100, got %g", total)
+	}
+	return nil
+}
+
+// errNoAllocationRules is returned when an income is allocated before any
+// rules are set.
+var errNoAllocationRules = errors.New("no allocation rules are configured")
+
+// allocateIncome splits the income transaction in the path across the
+// allocation rules and records the shares, replacing any earlier allocation
+// of it, in a single database transaction. Shares are rounded to the cent
+// and the last bucket absorbs the rounding so they add up exactly.
+func allocateIncome(c *gin.Context) {
+	var income Transaction
+	err := db.QueryRow("SELECT id, amount_cents, type FROM transactions WHERE id = ?", c.Param("id")).
+		Scan(&income.ID, &income.Amount, &income.Type)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if income.Type != TypeIncome || income.Amount <= 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "only positive income transactions can be allocated"})
+		return
+	}
+
+	var allocations []Allocation
+	err = withTx(func(tx *sql.Tx) error {
+		rules, err := queryAllocationRules(tx)
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return errNoAllocationRules
+		}
+
+		if _, err := tx.Exec("DELETE FROM allocations WHERE income_id = ?", income.ID); err != nil {
+			return err
+		}
+		allocations = make([]Allocation, len(rules))
+		remaining := income.Amount
+		for i, r := range rules {
+			a := Allocation{IncomeID: income.ID, Bucket: r.Bucket, Amount: remaining}
+			if i < len(rules)-1 {
+				a.Amount = Money(math.Round(float64(income.Amount) * r.Percent / 100))
+			}
+			remaining -= a.Amount
+
+			result, err := tx.Exec("INSERT INTO allocations (income_id, bucket, amount_cents) VALUES (?, ?, ?)", a.IncomeID, a.Bucket, a.Amount)
+			if err != nil {
+				return err
+			}
+			id, _ := result.LastInsertId()
+			a.ID = int(id)
+			allocations[i] = a
+		}
+		return nil
+	})
+	if errors.Is(err, errNoAllocationRules) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, allocations)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Attachment is a file, such as a receipt, attached to a transaction. The
+// bytes live in storage under StorageKey.
+type Attachment struct {
+	ID            int       `json:"id"`
+	TransactionID int       `json:"transaction_id"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	StorageKey    string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const attachmentColumns = "id, transaction_id, filename, content_type, size, storage_key, created_at"
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.TransactionID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt)
+	return a, err
+}
+
+// addAttachment stores the file uploaded in the "file" form field and
+// attaches it to the transaction in the path.
+func addAttachment(c *gin.Context) {
+	var transactionID int
+	err := db.QueryRow("SELECT id FROM transactions WHERE id = ?", c.Param("id")).Scan(&transactionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fh.Size > cfg.MaxAttachmentBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("attachments may be at most %d bytes", cfg.MaxAttachmentBytes)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	a := Attachment{
+		TransactionID: transactionID,
+		Filename:      filepath.Base(fh.Filename),
+		ContentType:   fh.Header.Get("Content-Type"),
+		Size:          fh.Size,
+	}
+	if a.ContentType == "" {
+		a.ContentType = "application/octet-stream"
+	}
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.StorageKey = fmt.Sprintf("transactions/%d/%s", transactionID, hex.EncodeToString(suffix))
+
+	if err := storage.Put(c.Request.Context(), a.StorageKey, f, a.Size, a.ContentType); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO attachments (transaction_id, filename, content_type, size, storage_key) VALUES (?, ?, ?, ?, ?)",
+		a.TransactionID, a.Filename, a.ContentType, a.Size, a.StorageKey,
+	)
+	if err != nil {
+		storage.Delete(c.Request.Context(), a.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, _ := result.LastInsertId()
+	a, err = scanAttachment(db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/attachments/%d", a.ID))
+	c.JSON(http.StatusCreated, a)
+}
+
+func getAttachments(c *gin.Context) {
+	rows, err := db.Query("SELECT "+attachmentColumns+" FROM attachments WHERE transaction_id = ? ORDER BY id", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		attachments = append(attachments, a)
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// downloadAttachment redirects to a presigned URL when the storage can
+// issue one, so the bytes don't pass through the API, and serves the file
+// itself otherwise.
+func downloadAttachment(c *gin.Context) {
+	a, err := scanAttachment(db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := storage.PresignedURL(a.StorageKey, a.Filename, cfg.AttachmentURLTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if u != "" {
+		c.Redirect(http.StatusFound, u)
+		return
+	}
+
+	r, err := storage.Open(c.Request.Context(), a.StorageKey)
+	if errors.Is(err, errObjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer r.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.Filename))
+	c.DataFromReader(http.StatusOK, a.Size, a.ContentType, r, nil)
+}
+
+func deleteAttachment(c *gin.Context) {
+	if err := removeAttachments(c.Request.Context(), "id = ?", c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// removeAttachments deletes the attachments matching where along with
+// their files. A file that can't be deleted is logged and left behind
+// rather than failing the caller.
+func removeAttachments(ctx context.Context, where string, args ...any) error {
+	var keys []string
+	err := withTx(func(tx *sql.Tx) error {
+		var err error
+		keys, err = deleteAttachmentRows(tx, where, args...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	deleteAttachmentFiles(ctx, keys)
+	return nil
+}
+
+// deleteAttachmentRows deletes the attachment rows matching where inside
+// tx and returns their storage keys, for the caller to pass to
+// deleteAttachmentFiles once tx commits.
+func deleteAttachmentRows(tx *sql.Tx, where string, args ...any) ([]string, error) {
+	rows, err := tx.Query("SELECT storage_key FROM attachments WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM attachments WHERE "+where, args...); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// deleteAttachmentFiles removes the given objects from storage, logging
+// rather than failing on any that can't be deleted.
+func deleteAttachmentFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := storage.Delete(ctx, key); err != nil {
+			log.Printf("attachments: deleting %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// attach stores a file for transaction id under key.
+func attach(t *testing.T, id int, key string) {
+	t.Helper()
+	if err := storage.Put(context.Background(), key, strings.NewReader("receipt"), 7, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO attachments (transaction_id, filename, content_type, size, storage_key) VALUES (?, 'r.txt', 'text/plain', 7, ?)", id, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// attachmentKeys lists the stored attachment keys whose file also exists.
+func attachmentKeys(t *testing.T) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT storage_key FROM attachments ORDER BY storage_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		r, err := storage.Open(context.Background(), key)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		r.Close()
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// TestClearKeepsArchivedAttachments checks that clearing transactions
+// removes only the cleared rows' attachments.
+func TestClearKeepsArchivedAttachments(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Now().AddDate(-5, 0, 0), Amount: -500, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: time.Now(), Amount: -700, Category: "Food", Type: TypeExpense})
+	attach(t, 1, "archived")
+	attach(t, 2, "live")
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(http.MethodDelete, "/api/transactions", "/api/transactions?confirm=true", "", "", clearTransactions)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if keys := attachmentKeys(t); len(keys) != 1 || keys[0] != "archived" {
+		t.Errorf("got attachments %v, want [archived]", keys)
+	}
+	if _, err := storage.Open(context.Background(), "live"); err != errObjectNotFound {
+		t.Errorf("cleared transaction's file: got %v, want errObjectNotFound", err)
+	}
+}
+
+// TestDeleteDuplicatesRemovesAttachments checks that the extra copies'
+// attachments go with them.
+func TestDeleteDuplicatesRemovesAttachments(t *testing.T) {
+	newTestDB(t)
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for range 2 {
+		mustInsert(t, Transaction{Date: date, Amount: -500, Category: "Food", Description: "Lunch", Type: TypeExpense})
+	}
+	attach(t, 1, "first")
+	attach(t, 2, "extra")
+
+	w := serve(http.MethodDelete, "/api/transactions/duplicates", "/api/transactions/duplicates?resolve=keep_first", "", "", deleteDuplicates)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if keys := attachmentKeys(t); len(keys) != 1 || keys[0] != "first" {
+		t.Errorf("got attachments %v, want [first]", keys)
+	}
+	if _, err := storage.Open(context.Background(), "extra"); err != errObjectNotFound {
+		t.Errorf("extra copy's file: got %v, want errObjectNotFound", err)
+	}
+}
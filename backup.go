@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupMu serializes backups so a manual one can't race the scheduled job.
+var backupMu sync.Mutex
+
+// Backup files are named backupPrefix + timestamp + backupSuffix, so they
+// sort oldest first.
+const (
+	backupPrefix = "finance-"
+	backupSuffix = ".db"
+)
+
+// Backup describes one backup file in BACKUP_DIR.
+type Backup struct {
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createBackup writes a consistent copy of the database to BACKUP_DIR with
+// VACUUM INTO, which is safe while the server keeps writing, then deletes
+// all but the newest BACKUP_KEEP backups.
+func createBackup() (Backup, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	return writeBackup()
+}
+
+// writeBackup does the work of createBackup; the caller holds backupMu.
+func writeBackup() (Backup, error) {
+	if err := os.MkdirAll(cfg.BackupDir, 0o755); err != nil {
+		return Backup{}, err
+	}
+	now := time.Now().UTC()
+	b := Backup{File: backupPrefix + now.Format("20060102-150405.000") + backupSuffix, CreatedAt: now}
+	path := filepath.Join(cfg.BackupDir, b.File)
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return Backup{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	b.Size = info.Size()
+	return b, pruneBackups()
+}
+
+// pruneBackups deletes the oldest backups beyond BACKUP_KEEP.
+func pruneBackups() error {
+	entries, err := os.ReadDir(cfg.BackupDir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > cfg.BackupKeep {
+		if err := os.Remove(filepath.Join(cfg.BackupDir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// backupDatabase takes a backup now, outside the BACKUP_INTERVAL schedule.
+func backupDatabase(c *gin.Context) {
+	b, err := createBackup()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// restoreDatabase replaces the database with the SQLite file uploaded in the
+// "file" form field, which must be a backup of this server's database at
+// its current or an older schema version; older ones are migrated before
+// use. The current database is backed up first. Because everything is
+// replaced, the request must pass confirm=true.
+func restoreDatabase(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "restoring replaces all data; pass confirm=true to proceed"})
+		return
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staged, err := os.CreateTemp("", "finance-restore-*.db")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(staged.Name())
+	if err := stageUpload(staged, fh); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	version, err := prepareRestore(staged.Name())
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	previous, err := writeBackup()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "backing up the current database: " + err.Error()})
+		return
+	}
+	if err := restoreFrom(c.Request.Context(), staged.Name()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summaryCache.clear()
+
+	c.JSON(http.StatusOK, gin.H{
+		"restored":        fh.Filename,
+		"schema_version":  version,
+		"previous_backup": previous.File,
+	})
+}
+
+// stageUpload copies the upload into f and closes it.
+func stageUpload(f *os.File, fh *multipart.FileHeader) error {
+	src, err := fh.Open()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer src.Close()
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// prepareRestore checks that path is an intact SQLite database with a
+// schema this server can use, migrates it to the current schema, and
+// returns the version it started at.
+func prepareRestore(path string) (int, error) {
+	d, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	var integrity string
+	if err := d.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("not a SQLite database: %w", err)
+	}
+	if integrity != "ok" {
+		return 0, errors.New("database failed its integrity check: " + integrity)
+	}
+	var version int
+	if err := d.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version < 1 || version > len(migrations) {
+		return 0, fmt.Errorf("schema version %d is not a backup of this server, which is at version %d", version, len(migrations))
+	}
+	if err := migrate(d); err != nil {
+		return 0, err
+	}
+	rows, err := d.Query("SELECT " + transactionColumns + " FROM transactions LIMIT 1")
+	if err != nil {
+		return 0, fmt.Errorf("unexpected schema: %w", err)
+	}
+	rows.Close()
+	return version, nil
+}
+
+// restoreFrom copies the database at path over the live one with SQLite's
+// online backup API. The copy runs on one of db's own connections, so the
+// handle every request shares never changes, and SQLite locks the database
+// for the duration: requests wait rather than seeing a half-restored file.
+func restoreFrom(ctx context.Context, path string) error {
+	src, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(dest any) error {
+		return srcConn.Raw(func(src any) error {
+			b, err := rawSQLiteConn(dest).Backup("main", rawSQLiteConn(src), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRestoreDatabase checks that a restore replaces the data in place,
+// keeping the same handle, while other requests keep reading.
+func TestRestoreDatabase(t *testing.T) {
+	newTestDB(t)
+	previous := cfg.BackupDir
+	cfg.BackupDir = t.TempDir()
+	t.Cleanup(func() { cfg.BackupDir = previous })
+
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mustInsert(t, Transaction{Date: date, Amount: -500, Category: "Food", Type: TypeExpense})
+	b, err := createBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := os.ReadFile(filepath.Join(cfg.BackupDir, b.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, Transaction{Date: date, Amount: -700, Category: "Food", Type: TypeExpense})
+
+	handle := db
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&n); err != nil {
+				t.Errorf("read during restore: %v", err)
+				return
+			}
+		}
+	}()
+
+	body, contentType := uploadBody(t, "backup.db", backup)
+	w := serve(http.MethodPost, "/api/admin/restore", "/api/admin/restore?confirm=true", contentType, body, restoreDatabase)
+	close(stop)
+	wg.Wait()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if db != handle {
+		t.Error("restore replaced the database handle")
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d transactions after restore, want 1", n)
+	}
+}
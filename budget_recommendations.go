@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetRecommendation suggests a monthly budget for a category from its
+// recent spending. Current is the category's default budget, if it has one.
+type BudgetRecommendation struct {
+	Category    string `json:"category"`
+	Average     Money  `json:"average"`
+	Recommended Money  `json:"recommended"`
+	Current     *Money `json:"current"`
+}
+
+// maxRecommendationMonths caps ?months= for budget recommendations.
+const maxRecommendationMonths = 24
+
+// getBudgetRecommendations suggests a budget for every category with
+// spending in the last ?months= complete months (default 3): the monthly
+// average, rounded up to a whole currency unit. Nothing is saved.
+func getBudgetRecommendations(c *gin.Context) {
+	recs, ok := budgetRecommendations(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, recs)
+}
+
+// applyBudgetRecommendations saves the recommendations as default budgets,
+// creating or replacing each category's default, and returns them.
+// Month-specific budgets are left alone.
+func applyBudgetRecommendations(c *gin.Context) {
+	recs, ok := budgetRecommendations(c)
+	if !ok {
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		for _, r := range recs {
+			result, err := tx.Exec("UPDATE budgets SET amount_cents = ? WHERE category = ? AND month IS NULL", r.Recommended, r.Category)
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				if _, err := tx.Exec("INSERT INTO budgets (category, month, amount_cents) VALUES (?, NULL, ?)", r.Category, r.Recommended); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range recs {
+		amount := recs[i].Recommended
+		recs[i].Current = &amount
+	}
+
+	c.JSON(http.StatusOK, recs)
+}
+
+// budgetRecommendations reads ?months= and computes the recommendations,
+// largest first. On failure it has already written the response.
+func budgetRecommendations(c *gin.Context) ([]BudgetRecommendation, bool) {
+	months, err := strconv.Atoi(c.DefaultQuery("months", "3"))
+	if err != nil || months < 1 || months > maxRecommendationMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("months must be between 1 and %d", maxRecommendationMonths)})
+		return nil, false
+	}
+
+	thisMonth, _ := time.Parse("2006-01", localMonth(time.Now()))
+	totals := map[string]Money{}
+	for i := 1; i <= months; i++ {
+		spent, err := spentByCategory(thisMonth.AddDate(0, -i, 0).Format("2006-01"), false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		for category, amount := range spent {
+			totals[category] += amount
+		}
+	}
+
+	current := map[string]Money{}
+	rows, err := db.Query("SELECT category, amount_cents FROM budgets WHERE month IS NULL")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var category string
+		var amount Money
+		if err := rows.Scan(&category, &amount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		current[category] = amount
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	recs := []BudgetRecommendation{}
+	for category, total := range totals {
+		if total <= 0 {
+			continue
+		}
+		r := BudgetRecommendation{Category: category, Average: divideMoney(total, months)}
+		unit := Money(months) * 100
+		r.Recommended = (total + unit - 1) / unit * 100
+		if amount, ok := current[category]; ok {
+			r.Current = &amount
+		}
+		recs = append(recs, r)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Recommended != recs[j].Recommended {
+			return recs[i].Recommended > recs[j].Recommended
+		}
+		return recs[i].Category < recs[j].Category
+	})
+	return recs, true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetSimulation is a set of hypothetical budgets, by category, to try
+// against this month's spending. Categories left out keep their current
+// budget.
+type BudgetSimulation struct {
+	Budgets map[string]Money `json:"budgets"`
+}
+
+// SimulatedCategory is one category's projected spending for the month.
+// Budget is the budget the projection was capped by, if any.
+type SimulatedCategory struct {
+	Category  string `json:"category"`
+	Spent     Money  `json:"spent"`
+	Budget    *Money `json:"budget"`
+	Projected Money  `json:"projected"`
+}
+
+// simulateBudgets projects this month's savings under the posted budgets.
+// Each category's spending so far is extrapolated to the end of the month
+// at its current daily pace, but no further than its budget, or what it
+// has already spent if that is more. Income is what has been received so
+// far, since it rarely arrives at a steady pace. baseline_savings is the
+// same projection under the current budgets, for comparison. Nothing is
+// saved.
+func simulateBudgets(c *gin.Context) {
+	var sim BudgetSimulation
+	if err := c.ShouldBindJSON(&sim); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	verr := ValidationError{}
+	if len(sim.Budgets) == 0 {
+		verr["budgets"] = "at least one budget is required"
+	}
+	for category, amount := range sim.Budgets {
+		if strings.TrimSpace(category) == "" {
+			verr["budgets"] = "category names must not be blank"
+		} else if amount < 0 {
+			verr["budgets."+category] = "must not be negative"
+		}
+	}
+	if err := verr.err(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr})
+		return
+	}
+
+	now := time.Now().In(time.FixedZone("", tzOffset()))
+	month := now.Format("2006-01")
+	start, _ := time.Parse("2006-01", month)
+	daysInMonth := start.AddDate(0, 1, -1).Day()
+	elapsed := now.Day()
+
+	statuses, spent, err := budgetStatuses(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summaries, err := queryMonthlySummaries(month, month, 0, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var income Money
+	if len(summaries) > 0 {
+		income = summaries[0].TotalIncome
+	}
+
+	current := map[string]Money{}
+	for _, s := range statuses {
+		current[s.Category] = s.Budget
+	}
+	project := func(category string, budgets map[string]Money) (Money, *Money) {
+		projected := spent[category] * Money(daysInMonth) / Money(elapsed)
+		budget, ok := budgets[category]
+		if !ok {
+			return projected, nil
+		}
+		if projected > budget {
+			projected = max(budget, spent[category])
+		}
+		return projected, &budget
+	}
+
+	simulated := map[string]Money{}
+	for category, amount := range current {
+		simulated[category] = amount
+	}
+	for category, amount := range sim.Budgets {
+		simulated[strings.TrimSpace(category)] = amount
+	}
+
+	var categories []string
+	for category := range spent {
+		categories = append(categories, category)
+	}
+	for category := range simulated {
+		if _, ok := spent[category]; !ok {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	lines := make([]SimulatedCategory, len(categories))
+	var projectedExpense, baselineExpense Money
+	for i, category := range categories {
+		projected, budget := project(category, simulated)
+		baseline, _ := project(category, current)
+		lines[i] = SimulatedCategory{Category: category, Spent: spent[category], Budget: budget, Projected: projected}
+		projectedExpense += projected
+		baselineExpense += baseline
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":             month,
+		"days_elapsed":      elapsed,
+		"days_in_month":     daysInMonth,
+		"income":            income,
+		"projected_expense": projectedExpense,
+		"projected_savings": income - projectedExpense,
+		"baseline_savings":  income - baselineExpense,
+		"categories":        lines,
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetTemplate is one category of the budget set copied into each new
+// month. Unlike a default budget it becomes a real month-specific budget,
+// which can then be adjusted for that month alone.
+type BudgetTemplate struct {
+	Category string `json:"category"`
+	Amount   Money  `json:"amount"`
+}
+
+func getBudgetTemplate(c *gin.Context) {
+	rows, err := db.Query("SELECT category, amount_cents FROM budget_templates ORDER BY category")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	templates := []BudgetTemplate{}
+	for rows.Next() {
+		var t BudgetTemplate
+		if err := rows.Scan(&t.Category, &t.Amount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// setBudgetTemplate replaces the whole template with the posted list.
+func setBudgetTemplate(c *gin.Context) {
+	var templates []BudgetTemplate
+	if err := c.ShouldBindJSON(&templates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := map[string]bool{}
+	for i := range templates {
+		b := Budget{Category: templates[i].Category, Amount: templates[i].Amount}
+		if err := validateBudget(&b); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if seen[b.Category] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category " + b.Category + " is listed more than once"})
+			return
+		}
+		seen[b.Category] = true
+		templates[i].Category = b.Category
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM budget_templates"); err != nil {
+			return err
+		}
+		for _, t := range templates {
+			if _, err := tx.Exec("INSERT INTO budget_templates (category, amount_cents) VALUES (?, ?)", t.Category, t.Amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// applyBudgetTemplate copies the template into the month given by ?month=
+// (default current). Categories that already have a budget for that month
+// keep it.
+func applyBudgetTemplate(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	n, err := applyTemplateToMonth(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"month": month, "created": n})
+}
+
+func applyTemplateToMonth(month string) (int64, error) {
+	var n int64
+	err := withTx(func(tx *sql.Tx) error {
+		var err error
+		n, err = copyTemplate(tx, month)
+		return err
+	})
+	return n, err
+}
+
+// copyTemplate inserts the template's budgets for month and records the
+// month as applied so the rollover job leaves it alone.
+func copyTemplate(tx *sql.Tx, month string) (int64, error) {
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO budgets (category, month, amount_cents)
+		SELECT category, ?, amount_cents FROM budget_templates
+	`, month)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO budget_template_months (month) VALUES (?)", month); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rolloverBudgetTemplate applies the template to the current month the
+// first time it runs in that month. Months applied by hand, or already
+// rolled over, are skipped so budgets deleted afterwards stay deleted.
+func rolloverBudgetTemplate() (int64, error) {
+	month := time.Now().In(cfg.Timezone).Format("2006-01")
+
+	var n int64
+	err := withTx(func(tx *sql.Tx) error {
+		var applied bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM budget_template_months WHERE month = ?)", month).Scan(&applied); err != nil {
+			return err
+		}
+		var empty bool
+		if err := tx.QueryRow("SELECT NOT EXISTS (SELECT 1 FROM budget_templates)").Scan(&empty); err != nil {
+			return err
+		}
+		if applied || empty {
+			return nil
+		}
+
+		var err error
+		n, err = copyTemplate(tx, month)
+		return err
+	})
+	return n, err
+}
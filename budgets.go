@@ -0,0 +1,426 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattn/go-sqlite3"
+)
+
+// BudgetStatus compares a category's effective budget for a month against
+// what was actually spent in it.
+type BudgetStatus struct {
+	Category  string `json:"category"`
+	Month     string `json:"month"`
+	Budget    Money  `json:"budget"`
+	Spent     Money  `json:"spent"`
+	Remaining Money  `json:"remaining"`
+	Recurring bool   `json:"recurring"`
+
+	// Avg3Mo is the average monthly spending in the category over the three
+	// months before Month, counting months with no spending as zero.
+	Avg3Mo Money `json:"avg_3mo"`
+}
+
+func getBudgets(c *gin.Context) {
+	rows, err := db.Query("SELECT category, month, amount_cents FROM budgets ORDER BY category, month")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.Category, &b.Month, &b.Amount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		budgets = append(budgets, b)
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+func addBudget(c *gin.Context) {
+	var b Budget
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBudget(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := db.Exec("INSERT INTO budgets (category, month, amount_cents) VALUES (?, ?, ?)", b.Category, b.Month, b.Amount)
+	if isUniqueViolation(err) {
+		msg := "a default budget for category " + b.Category + " already exists"
+		if b.Month != nil {
+			msg = "a budget for category " + b.Category + " already exists for " + *b.Month
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, b)
+}
+
+// updateBudget changes the amount of the budget identified by category and
+// the optional month query parameter; without it the default budget is
+// updated.
+func updateBudget(c *gin.Context) {
+	var b Budget
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b.Category = c.Param("category")
+	b.Month = nil
+	if month := c.Query("month"); month != "" {
+		b.Month = &month
+	}
+	if err := validateBudget(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec(
+		"UPDATE budgets SET amount_cents = ? WHERE category = ? AND month IS ?",
+		b.Amount, b.Category, b.Month,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+func deleteBudget(c *gin.Context) {
+	var month *string
+	if m := c.Query("month"); m != "" {
+		month = &m
+	}
+	_, err := db.Exec("DELETE FROM budgets WHERE category = ? AND month IS ?", c.Param("category"), month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getBudgetStatus(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses, _, err := budgetStatuses(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+// getBudgetSummary is the at-a-glance view for a month: every budgeted
+// category, the expense categories that have no budget, and overall totals.
+func getBudgetSummary(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses, spent, err := budgetStatuses(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type unbudgetedCategory struct {
+		Category string `json:"category"`
+		Spent    Money  `json:"spent"`
+	}
+
+	var totalBudget, totalSpent, unbudgetedSpent Money
+	budgeted := map[string]bool{}
+	for _, s := range statuses {
+		budgeted[s.Category] = true
+		totalBudget += s.Budget
+	}
+	unbudgeted := []unbudgetedCategory{}
+	for category, amount := range spent {
+		totalSpent += amount
+		if !budgeted[category] {
+			unbudgetedSpent += amount
+			unbudgeted = append(unbudgeted, unbudgetedCategory{category, amount})
+		}
+	}
+	sort.Slice(unbudgeted, func(i, j int) bool { return unbudgeted[i].Spent > unbudgeted[j].Spent })
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":      month,
+		"categories": statuses,
+		"unbudgeted": gin.H{
+			"spent":      unbudgetedSpent,
+			"categories": unbudgeted,
+		},
+		"total_budget":    totalBudget,
+		"total_spent":     totalSpent,
+		"total_remaining": totalBudget - totalSpent,
+	})
+}
+
+// BurndownDay is one day of a budget burn-down. Actual is the cumulative
+// spend through the end of the day and is null for days still to come.
+type BurndownDay struct {
+	Date   string `json:"date"`
+	Ideal  Money  `json:"ideal"`
+	Actual *Money `json:"actual"`
+}
+
+// getBudgetBurndown charts a category's spending over ?month= (default the
+// current month) against its budget: for each day the ideal spend if the
+// budget were used evenly, and the actual cumulative spend.
+func getBudgetBurndown(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category := c.Param("category")
+
+	budgets, err := effectiveBudgets(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var budget Money
+	found := false
+	for _, b := range budgets {
+		if b.Category == category {
+			budget, found = b.Amount, true
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no budget for category " + category + " in " + month})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT CAST(strftime('%d', datetime(date, ?)) AS INTEGER), SUM(CASE WHEN type = ? THEN -ABS(amount_cents) ELSE ABS(amount_cents) END)
+		FROM transactions
+		WHERE type IN (?, ?) AND NOT reimbursed AND COALESCE(budget_category, category) = ? AND strftime('%Y-%m', datetime(date, ?)) = ?
+		GROUP BY 1
+	`, tzModifier(), TypeRefund, TypeExpense, TypeRefund, category, tzModifier(), month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	start, _ := time.Parse("2006-01", month)
+	daysInMonth := start.AddDate(0, 1, -1).Day()
+	daily := make([]Money, daysInMonth+1)
+	for rows.Next() {
+		var day int
+		var total Money
+		if err := rows.Scan(&day, &total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		daily[day] = total
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	today := time.Now().In(time.FixedZone("", tzOffset())).Format("2006-01-02")
+	days := make([]BurndownDay, daysInMonth)
+	var spent Money
+	for d := 1; d <= daysInMonth; d++ {
+		day := &days[d-1]
+		day.Date = start.AddDate(0, 0, d-1).Format("2006-01-02")
+		day.Ideal = budget * Money(d) / Money(daysInMonth)
+		spent += daily[d]
+		if day.Date <= today {
+			actual := spent
+			day.Actual = &actual
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"category": category,
+		"month":    month,
+		"budget":   budget,
+		"spent":    spent,
+		"days":     days,
+	})
+}
+
+// budgetStatuses computes the status of every budgeted category for month.
+// It also returns the month's spending for all categories, budgeted or not.
+func budgetStatuses(month string) ([]BudgetStatus, map[string]Money, error) {
+	budgets, err := effectiveBudgets(month)
+	if err != nil {
+		return nil, nil, err
+	}
+	spent, err := spentByCategory(month, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	averages, err := averageSpentByCategory(month, 3)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statuses := []BudgetStatus{}
+	for _, b := range budgets {
+		statuses = append(statuses, BudgetStatus{
+			Category:  b.Category,
+			Month:     month,
+			Budget:    b.Amount,
+			Spent:     spent[b.Category],
+			Remaining: b.Amount - spent[b.Category],
+			Recurring: b.Month == nil,
+			Avg3Mo:    averages[b.Category],
+		})
+	}
+	return statuses, spent, nil
+}
+
+// effectiveBudgets returns one budget per category for month: the
+// month-specific budget when there is one, otherwise the default budget.
+func effectiveBudgets(month string) ([]Budget, error) {
+	rows, err := db.Query(`
+		SELECT category, month, amount_cents
+		FROM budgets b
+		WHERE month = ?
+			OR (month IS NULL AND NOT EXISTS (
+				SELECT 1 FROM budgets m WHERE m.category = b.category AND m.month = ?
+			))
+		ORDER BY category
+	`, month, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.Category, &b.Month, &b.Amount); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// spentByCategory sums expenses less refunds per budget category for a
+// YYYY-MM month, as positive amounts. A transaction's budget_category
+// overrides its category; reimbursed transactions don't count, and neither
+// do pending ones when clearedOnly is set.
+func spentByCategory(month string, clearedOnly bool) (map[string]Money, error) {
+	query := `
+		SELECT COALESCE(budget_category, category), SUM(CASE WHEN type = ? THEN -ABS(amount_cents) ELSE ABS(amount_cents) END)
+		FROM transactions
+		WHERE type IN (?, ?) AND NOT reimbursed AND strftime('%Y-%m', datetime(date, ?)) = ?`
+	args := []any{TypeRefund, TypeExpense, TypeRefund, tzModifier(), month}
+	if clearedOnly {
+		query += " AND status = ?"
+		args = append(args, StatusCleared)
+	}
+	rows, err := db.Query(query+" GROUP BY 1", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spent := map[string]Money{}
+	for rows.Next() {
+		var category string
+		var total Money
+		if err := rows.Scan(&category, &total); err != nil {
+			return nil, err
+		}
+		spent[category] = total
+	}
+	return spent, rows.Err()
+}
+
+// averageSpentByCategory is spentByCategory averaged over the n months
+// before month, in one query whose WHERE clause is that window. Months with
+// no spending in a category count as zero, so summing the window and
+// dividing by n gives the same average as a window function over
+// zero-filled monthly totals, without needing a row per month.
+func averageSpentByCategory(month string, n int) (map[string]Money, error) {
+	m, _ := time.Parse("2006-01", month)
+	from := m.AddDate(0, -n, 0).Format("2006-01")
+	to := m.AddDate(0, -1, 0).Format("2006-01")
+
+	tz := tzModifier()
+	rows, err := db.Query(`
+		SELECT COALESCE(budget_category, category), SUM(CASE WHEN type = ? THEN -ABS(amount_cents) ELSE ABS(amount_cents) END)
+		FROM transactions
+		WHERE type IN (?, ?) AND NOT reimbursed AND strftime('%Y-%m', datetime(date, ?)) BETWEEN ? AND ?
+		GROUP BY 1
+	`, TypeRefund, TypeExpense, TypeRefund, tz, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	averages := map[string]Money{}
+	for rows.Next() {
+		var category string
+		var total Money
+		if err := rows.Scan(&category, &total); err != nil {
+			return nil, err
+		}
+		averages[category] = divideMoney(total, n)
+	}
+	return averages, rows.Err()
+}
+
+func validateBudget(b *Budget) error {
+	b.Category = strings.TrimSpace(b.Category)
+	if b.Category == "" {
+		return errors.New("category is required")
+	}
+	if b.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if b.Month != nil && *b.Month == "" {
+		b.Month = nil
+	}
+	if b.Month != nil && !isMonth(*b.Month) {
+		return errors.New("month must be in YYYY-MM format or null")
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err came from a UNIQUE constraint, so
+// handlers can answer 409 instead of leaking the raw database error.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
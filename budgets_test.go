@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddBudgetDuplicateMonth(t *testing.T) {
+	newTestDB(t)
+	post := func(body string) int {
+		return serve(http.MethodPost, "/api/budgets", "/api/budgets", "application/json", body, addBudget).Code
+	}
+
+	if code := post(`{"category":"Food","month":"2024-03","amount":200}`); code != http.StatusCreated {
+		t.Fatalf("first budget: got %d, want 201", code)
+	}
+	if code := post(`{"category":"Food","month":"2024-03","amount":250}`); code != http.StatusConflict {
+		t.Errorf("same category and month: got %d, want 409", code)
+	}
+	if code := post(`{"category":"Food","month":"2024-04","amount":250}`); code != http.StatusCreated {
+		t.Errorf("same category, other month: got %d, want 201", code)
+	}
+	if code := post(`{"category":"Food","amount":150}`); code != http.StatusCreated {
+		t.Errorf("default budget: got %d, want 201", code)
+	}
+	if code := post(`{"category":"Food","amount":175}`); code != http.StatusConflict {
+		t.Errorf("second default budget: got %d, want 409", code)
+	}
+}
+
+// TestAverageSpentByCategory checks that the average covers exactly the
+// three months before the budget month, counting empty months as zero.
+func TestAverageSpentByCategory(t *testing.T) {
+	newTestDB(t)
+	for _, tr := range []struct {
+		month  time.Month
+		year   int
+		amount Money
+	}{
+		{time.December, 2023, -5000},
+		{time.January, 2024, -100},
+		{time.March, 2024, -1},
+		{time.April, 2024, -7000},
+	} {
+		mustInsert(t, Transaction{Date: time.Date(tr.year, tr.month, 15, 12, 0, 0, 0, time.UTC), Amount: tr.amount, Category: "Food", Type: TypeExpense})
+	}
+
+	averages, err := averageSpentByCategory("2024-04", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := averages["Food"]; got != 34 {
+		t.Errorf("average = %s, want 0.34", got)
+	}
+}
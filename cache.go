@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryCache holds recent responses of the /api/summary endpoints, keyed
+// by path and query string, so repeated dashboard loads don't re-run the
+// same aggregates. Any write request empties it. It is disabled when
+// SUMMARY_CACHE_TTL is zero.
+var summaryCache = &responseCache{entries: map[string]cachedResponse{}}
+
+type cachedResponse struct {
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+	// generation is bumped by every clear so a response computed before a
+	// write isn't stored after it.
+	generation uint64
+}
+
+func (rc *responseCache) get(key string) (cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if ok && time.Now().After(e.expires) {
+		delete(rc.entries, key)
+		return e, false
+	}
+	return e, ok
+}
+
+func (rc *responseCache) put(key string, e cachedResponse, generation uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if generation == rc.generation {
+		rc.entries[key] = e
+	}
+}
+
+func (rc *responseCache) currentGeneration() uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.generation
+}
+
+// clear drops every entry. Call it after anything that changes data the
+// summaries read, including background jobs.
+func (rc *responseCache) clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	clear(rc.entries)
+	rc.generation++
+}
+
+// middleware serves cached summary responses and empties the cache after
+// every request that may have written.
+func (rc *responseCache) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.SummaryCacheTTL <= 0 {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodOptions:
+			c.Next()
+			return
+		default:
+			c.Next()
+			rc.clear()
+			return
+		}
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/summary/") {
+			c.Next()
+			return
+		}
+
+		key := c.Request.URL.RequestURI()
+		if e, ok := rc.get(key); ok {
+			for k, v := range e.header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header("X-Cache", "HIT")
+			c.Data(http.StatusOK, e.header.Get("Content-Type"), e.body)
+			c.Abort()
+			return
+		}
+
+		generation := rc.currentGeneration()
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header("X-Cache", "MISS")
+		c.Next()
+		if w.Status() == http.StatusOK {
+			rc.put(key, cachedResponse{
+				header:  w.Header().Clone(),
+				body:    w.body.Bytes(),
+				expires: time.Now().Add(cfg.SummaryCacheTTL),
+			}, generation)
+		}
+	}
+}
+
+// recordingWriter keeps a copy of everything written through it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryMeta holds display hints for a category so every chart draws it
+// the same way. SpendingType classifies an expense category as fixed or
+// discretionary, or is empty when it hasn't been classified.
+type CategoryMeta struct {
+	Category     string `json:"category"`
+	Color        string `json:"color"`
+	Icon         string `json:"icon"`
+	SpendingType string `json:"spending_type"`
+}
+
+// Spending types for expense categories.
+const (
+	SpendingFixed         = "fixed"
+	SpendingDiscretionary = "discretionary"
+)
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func getCategoryMeta(c *gin.Context) {
+	rows, err := db.Query("SELECT category, color, icon, spending_type FROM category_meta ORDER BY category")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	metas := []CategoryMeta{}
+	for rows.Next() {
+		var m CategoryMeta
+		if err := rows.Scan(&m.Category, &m.Color, &m.Icon, &m.SpendingType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		metas = append(metas, m)
+	}
+
+	c.JSON(http.StatusOK, metas)
+}
+
+// setCategoryMeta creates or replaces the metadata for a category.
+func setCategoryMeta(c *gin.Context) {
+	var m CategoryMeta
+	if err := c.ShouldBindJSON(&m); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m.Category = c.Param("category")
+	if err := validateCategoryMeta(&m); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO category_meta (category, color, icon, spending_type) VALUES (?, ?, ?, ?)
+		ON CONFLICT (category) DO UPDATE SET color = excluded.color, icon = excluded.icon, spending_type = excluded.spending_type
+	`, m.Category, m.Color, m.Icon, m.SpendingType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, m)
+}
+
+func deleteCategoryMeta(c *gin.Context) {
+	_, err := db.Exec("DELETE FROM category_meta WHERE category = ?", c.Param("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func validateCategoryMeta(m *CategoryMeta) error {
+	m.Category = strings.TrimSpace(m.Category)
+	m.Icon = strings.TrimSpace(m.Icon)
+	if m.Category == "" {
+		return errors.New("category is required")
+	}
+	if m.Color != "" && !hexColor.MatchString(m.Color) {
+		return errors.New("color must be a hex color such as #1f77b4")
+	}
+	if len(m.Icon) > 64 {
+		return errors.New("icon must be at most 64 characters")
+	}
+	m.SpendingType = strings.ToLower(strings.TrimSpace(m.SpendingType))
+	if m.SpendingType != "" && m.SpendingType != SpendingFixed && m.SpendingType != SpendingDiscretionary {
+		return errors.New("spending_type must be fixed, discretionary or empty")
+	}
+	return nil
+}
+
+// StaleCategory is a category with no transactions in the requested
+// window. LastUsed is the date of its newest transaction, archived ones
+// included, or null for a category that only has a budget or metadata.
+type StaleCategory struct {
+	Category string  `json:"category"`
+	LastUsed *string `json:"last_used"`
+}
+
+// getStaleCategories lists categories with no transactions in the last
+// ?days= days (default 90), least recently used first, as candidates for
+// merging or cleanup.
+func getStaleCategories(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT category, MAX(used)
+		FROM (
+			SELECT category, date(date) AS used FROM transactions
+			UNION ALL SELECT category, date(date) FROM archived_transactions
+			UNION ALL SELECT category, NULL FROM budgets
+			UNION ALL SELECT category, NULL FROM category_meta
+		)
+		GROUP BY category
+		HAVING MAX(used) IS NULL OR MAX(used) < date('now', ?)
+		ORDER BY 2, 1
+	`, "-"+strconv.Itoa(days)+" days")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	stale := []StaleCategory{}
+	for rows.Next() {
+		var s StaleCategory
+		if err := rows.Scan(&s.Category, &s.LastUsed); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		stale = append(stale, s)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stale)
+}
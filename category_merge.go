@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryMerge folds the From categories into Into.
+type CategoryMerge struct {
+	From []string `json:"from"`
+	Into string   `json:"into"`
+}
+
+// mergeCategories moves every transaction, archived transaction, recurring
+// rule and budget in the From categories to Into, in a single database
+// transaction, and answers with the number of transactions moved. Budgets
+// that end up sharing a month are added together, and the merged
+// categories' display metadata is dropped in favour of Into's. Nothing is
+// merged if any affected transaction is in a closed period.
+func mergeCategories(c *gin.Context) {
+	var m CategoryMerge
+	if err := c.ShouldBindJSON(&m); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateCategoryMerge(&m); err != nil {
+		var verr ValidationError
+		errors.As(err, &verr)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr})
+		return
+	}
+	in, args := inList(m.From)
+	for _, table := range []string{"transactions", "archived_transactions"} {
+		if err := checkNoClosedRows(table, "WHERE category IN "+in+" OR budget_category IN "+in, append(args, args...)...); err != nil {
+			respondPeriodError(c, err)
+			return
+		}
+	}
+
+	var moved int64
+	err := withTx(func(tx *sql.Tx) error {
+		for _, table := range []string{"transactions", "archived_transactions", "recurring_transactions"} {
+			result, err := tx.Exec("UPDATE "+table+" SET category = ? WHERE category IN "+in, append([]any{m.Into}, args...)...)
+			if err != nil {
+				return err
+			}
+			if table == "transactions" {
+				moved, _ = result.RowsAffected()
+			}
+		}
+		for _, table := range []string{"transactions", "archived_transactions"} {
+			if _, err := tx.Exec("UPDATE "+table+" SET budget_category = ? WHERE budget_category IN "+in, append([]any{m.Into}, args...)...); err != nil {
+				return err
+			}
+		}
+		if err := mergeBudgets(tx, m); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM category_meta WHERE category IN "+in, args...)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"into": m.Into, "moved": moved})
+}
+
+// mergeBudgets rewrites the budgets and template entries of the merged
+// categories as Into's, summing amounts that fall in the same month.
+func mergeBudgets(tx *sql.Tx, m CategoryMerge) error {
+	in, args := inList(append(m.From, m.Into))
+
+	rows, err := tx.Query("SELECT month, SUM(amount_cents) FROM budgets WHERE category IN "+in+" GROUP BY COALESCE(month, '')", args...)
+	if err != nil {
+		return err
+	}
+	var budgets []Budget
+	for rows.Next() {
+		b := Budget{Category: m.Into}
+		if err := rows.Scan(&b.Month, &b.Amount); err != nil {
+			rows.Close()
+			return err
+		}
+		budgets = append(budgets, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM budgets WHERE category IN "+in, args...); err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		if _, err := tx.Exec("INSERT INTO budgets (category, month, amount_cents) VALUES (?, ?, ?)", b.Category, b.Month, b.Amount); err != nil {
+			return err
+		}
+	}
+
+	var template sql.NullInt64
+	if err := tx.QueryRow("SELECT SUM(amount_cents) FROM budget_templates WHERE category IN "+in, args...).Scan(&template); err != nil {
+		return err
+	}
+	if !template.Valid {
+		return nil
+	}
+	if _, err := tx.Exec("DELETE FROM budget_templates WHERE category IN "+in, args...); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO budget_templates (category, amount_cents) VALUES (?, ?)", m.Into, template.Int64)
+	return err
+}
+
+// inList renders values as a parenthesised list of placeholders for an IN
+// condition, with the matching arguments.
+func inList(values []string) (string, []any) {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ") + ")", args
+}
+
+func validateCategoryMerge(m *CategoryMerge) error {
+	errs := ValidationError{}
+	m.Into = strings.TrimSpace(m.Into)
+	if m.Into == "" {
+		errs["into"] = "is required"
+	}
+
+	var from []string
+	for _, f := range m.From {
+		if f = strings.TrimSpace(f); f != "" && f != m.Into {
+			from = append(from, f)
+		}
+	}
+	m.From = from
+	if len(m.From) == 0 {
+		errs["from"] = "must list at least one category other than into"
+	}
+	return errs.err()
+}
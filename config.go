@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config holds the settings read from the environment at startup.
+type Config struct {
+	Currency   string
+	Locale     string
+	DateFormat string
+
+	// Timezone is the zone month and weekday boundaries are drawn in.
+	Timezone *time.Location
+
+	// ImportTimezone is the zone imported dates without an offset are read
+	// in, unless the import passes ?tz=. It defaults to Timezone.
+	ImportTimezone *time.Location
+
+	// DefaultTransactionType fills in the type of manually added
+	// transactions that omit it. Empty means type is required.
+	DefaultTransactionType string
+
+	// UncategorizedLabel is the category given to transactions added or
+	// imported without one, such as "Uncategorized". Empty means category
+	// is required.
+	UncategorizedLabel string
+
+	// MaxDescriptionLength caps descriptions, in characters, on add and
+	// import. Longer ones are truncated with an ellipsis, or rejected when
+	// DescriptionOverflow is "reject". Zero disables the limit.
+	MaxDescriptionLength int
+	DescriptionOverflow  string
+
+	// RejectFractionalCents rejects amounts with more than two decimal
+	// places, such as 12.999, instead of rounding them to the cent.
+	RejectFractionalCents bool
+
+	// MaxFutureDays is how far past today a transaction may be dated
+	// before it is rejected as a likely typo.
+	MaxFutureDays int
+
+	// FiscalYearStartMonth is the calendar month (1-12) a fiscal year
+	// begins in.
+	FiscalYearStartMonth int
+
+	// ArchiveAfterYears moves transactions older than this many years to
+	// archived_transactions once a day. Zero disables the job.
+	ArchiveAfterYears int
+
+	// Database backups go to BackupDir, which keeps the newest BackupKeep.
+	// A BackupInterval of zero disables scheduled backups, leaving only
+	// POST /api/admin/backup.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// DefaultPageSize is the page size of the paginated transaction list
+	// when the request doesn't pass a limit. It may not exceed maxPageSize.
+	DefaultPageSize int
+
+	// MaxBodyBytes caps request bodies other than multipart uploads.
+	MaxBodyBytes int64
+
+	// MaxImportRows caps the rows a single CSV import may contain.
+	MaxImportRows int
+
+	// ImportMaxZeroAmountPercent rejects an import in which more than this
+	// percentage of rows have a zero amount, which usually means the amount
+	// column was mapped wrong.
+	ImportMaxZeroAmountPercent int
+
+	// Limits for importing CSVs from a URL. An empty allowlist permits any
+	// public host.
+	ImportURLTimeout      time.Duration
+	ImportURLMaxBytes     int64
+	ImportURLAllowedHosts []string
+
+	// ImportWatchDir, when set, is checked every ImportWatchInterval for
+	// CSV files to import.
+	ImportWatchDir      string
+	ImportWatchInterval time.Duration
+
+	// Attachment storage. Files go to S3 when S3Bucket is set and to
+	// AttachmentsDir on local disk otherwise. S3Endpoint points at an
+	// S3-compatible service instead of AWS.
+	AttachmentsDir     string
+	MaxAttachmentBytes int64
+	AttachmentURLTTL   time.Duration
+	S3Bucket           string
+	S3Region           string
+	S3Endpoint         string
+	S3AccessKeyID      string
+	S3SecretAccessKey  string
+	S3SessionToken     string
+
+	// Delivery settings for the monthly report. A webhook URL takes
+	// precedence over SMTP.
+	ReportWebhookURL string
+	SMTPAddr         string
+	SMTPUsername     string
+	SMTPPassword     string
+	ReportEmailFrom  string
+	ReportEmailTo    []string
+	ReportTimeout    time.Duration
+
+	// ResponseEnvelope wraps every JSON response as
+	// {"success", "data", "error"}.
+	ResponseEnvelope bool
+
+	// SummaryCacheTTL is how long summary responses are cached. Zero
+	// disables the cache.
+	SummaryCacheTTL time.Duration
+
+	// CORSMaxAge is how long browsers may cache a preflight response.
+	CORSMaxAge time.Duration
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// DebugSQL logs every query with its arguments and duration. It is
+	// verbose and writes amounts to the log, so leave it off in production.
+	DebugSQL bool
+}
+
+var cfg Config
+
+func loadConfig() {
+	tz := envLocation("TIMEZONE", time.UTC)
+	cfg = Config{
+		Currency:   strings.ToUpper(envString("CURRENCY", "USD")),
+		Locale:     envString("LOCALE", "en-US"),
+		DateFormat: envString("DATE_FORMAT", "YYYY-MM-DD"),
+
+		Timezone:       tz,
+		ImportTimezone: envLocation("IMPORT_TIMEZONE", tz),
+
+		DefaultTransactionType: strings.ToLower(envString("DEFAULT_TRANSACTION_TYPE", "")),
+
+		UncategorizedLabel: envString("UNCATEGORIZED_LABEL", ""),
+
+		MaxDescriptionLength: envInt("MAX_DESCRIPTION_LENGTH", 500),
+		DescriptionOverflow:  strings.ToLower(envString("DESCRIPTION_OVERFLOW", "truncate")),
+
+		RejectFractionalCents: envString("AMOUNT_PRECISION", "round") == "reject",
+
+		MaxFutureDays: envInt("MAX_FUTURE_DAYS", 7),
+
+		FiscalYearStartMonth: envInt("FISCAL_YEAR_START_MONTH", 1),
+
+		ArchiveAfterYears: envInt("ARCHIVE_AFTER_YEARS", 0),
+
+		BackupDir:      envString("BACKUP_DIR", "./backups"),
+		BackupInterval: envDuration("BACKUP_INTERVAL", 0),
+		BackupKeep:     envInt("BACKUP_KEEP", 7),
+
+		DefaultPageSize: envInt("DEFAULT_PAGE_SIZE", 50),
+
+		MaxBodyBytes: int64(envInt("MAX_BODY_BYTES", 1<<20)),
+
+		MaxImportRows: envInt("MAX_IMPORT_ROWS", 50000),
+
+		ImportMaxZeroAmountPercent: envInt("IMPORT_MAX_ZERO_AMOUNT_PERCENT", 90),
+
+		ImportURLTimeout:      envDuration("IMPORT_URL_TIMEOUT", 15*time.Second),
+		ImportURLMaxBytes:     int64(envInt("IMPORT_URL_MAX_BYTES", 10<<20)),
+		ImportURLAllowedHosts: envList("IMPORT_URL_ALLOWED_HOSTS"),
+
+		ImportWatchDir:      envString("IMPORT_WATCH_DIR", ""),
+		ImportWatchInterval: envDuration("IMPORT_WATCH_INTERVAL", time.Minute),
+
+		AttachmentsDir:     envString("ATTACHMENTS_DIR", "./attachments"),
+		MaxAttachmentBytes: int64(envInt("MAX_ATTACHMENT_BYTES", 10<<20)),
+		AttachmentURLTTL:   envDuration("ATTACHMENT_URL_TTL", 15*time.Minute),
+		S3Bucket:           envString("S3_BUCKET", ""),
+		S3Region:           envString("S3_REGION", "us-east-1"),
+		S3Endpoint:         envString("S3_ENDPOINT", ""),
+		S3AccessKeyID:      envString("AWS_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:  os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		S3SessionToken:     os.Getenv("AWS_SESSION_TOKEN"),
+
+		ReportWebhookURL: envString("REPORT_WEBHOOK_URL", ""),
+		SMTPAddr:         envString("SMTP_ADDR", ""),
+		SMTPUsername:     envString("SMTP_USERNAME", ""),
+		SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+		ReportEmailFrom:  envString("REPORT_EMAIL_FROM", "finance-dashboard@localhost"),
+		ReportEmailTo:    envList("REPORT_EMAIL_TO"),
+		ReportTimeout:    envDuration("REPORT_TIMEOUT", 30*time.Second),
+
+		ResponseEnvelope: os.Getenv("RESPONSE_ENVELOPE") == "true",
+
+		SummaryCacheTTL: envDuration("SUMMARY_CACHE_TTL", 0),
+
+		CORSMaxAge: envDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		ReadTimeout:  envDuration("READ_TIMEOUT", 15*time.Second),
+		WriteTimeout: envDuration("WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:  envDuration("IDLE_TIMEOUT", 120*time.Second),
+
+		DebugSQL: os.Getenv("DEBUG_SQL") == "true",
+	}
+
+	if cfg.FiscalYearStartMonth < 1 || cfg.FiscalYearStartMonth > 12 {
+		panic("FISCAL_YEAR_START_MONTH must be between 1 and 12")
+	}
+	if cfg.S3Bucket != "" && (cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "") {
+		panic("S3_BUCKET requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if p := envString("AMOUNT_PRECISION", "round"); p != "round" && p != "reject" {
+		panic("AMOUNT_PRECISION must be round or reject")
+	}
+	if cfg.BackupKeep < 1 {
+		panic("BACKUP_KEEP must be at least 1")
+	}
+	if cfg.ImportWatchDir != "" && cfg.ImportWatchInterval <= 0 {
+		panic("IMPORT_WATCH_INTERVAL must be positive")
+	}
+	if cfg.MaxBodyBytes < 1 {
+		panic("MAX_BODY_BYTES must be positive")
+	}
+	if cfg.MaxDescriptionLength < 0 {
+		panic("MAX_DESCRIPTION_LENGTH must not be negative")
+	}
+	if cfg.DescriptionOverflow != "truncate" && cfg.DescriptionOverflow != "reject" {
+		panic("DESCRIPTION_OVERFLOW must be truncate or reject")
+	}
+	if cfg.ImportMaxZeroAmountPercent < 0 || cfg.ImportMaxZeroAmountPercent > 100 {
+		panic("IMPORT_MAX_ZERO_AMOUNT_PERCENT must be between 0 and 100")
+	}
+	if cfg.DefaultTransactionType != "" && !isTransactionType(cfg.DefaultTransactionType) {
+		panic("DEFAULT_TRANSACTION_TYPE " + transactionTypeError())
+	}
+	if cfg.DefaultPageSize < 1 || cfg.DefaultPageSize > maxPageSize {
+		panic(fmt.Sprintf("DEFAULT_PAGE_SIZE must be between 1 and %d", maxPageSize))
+	}
+}
+
+func envString(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envInt reads an integer, panicking on anything that doesn't parse.
+func envInt(key string, fallback int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		panic(fmt.Errorf("%s must be an integer: %w", key, err))
+	}
+	return n
+}
+
+// envList reads a comma-separated list, lowercased and without blanks.
+func envList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// envLocation reads an IANA zone name such as America/New_York.
+func envLocation(key string, fallback *time.Location) *time.Location {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		panic(fmt.Errorf("%s must be a time zone name such as America/New_York: %w", key, err))
+	}
+	return loc
+}
+
+// tzModifier is the SQLite datetime modifier that shifts a stored date into
+// cfg.Timezone, for use as datetime(date, ?). SQLite only applies fixed
+// offsets, so the zone's standard offset is used year-round; during daylight
+// saving time a row within an hour of midnight may fall on the previous day.
+func tzModifier() string {
+	return fmt.Sprintf("%+d minutes", tzOffset()/60)
+}
+
+// localMonth is the YYYY-MM month t falls in, computed the same way as
+// strftime('%Y-%m', datetime(date, tzModifier())).
+func localMonth(t time.Time) string {
+	return t.In(time.FixedZone("", tzOffset())).Format("2006-01")
+}
+
+// tzOffset is cfg.Timezone's standard offset from UTC in seconds.
+func tzOffset() int {
+	year := time.Now().Year()
+	_, jan := time.Date(year, time.January, 1, 0, 0, 0, 0, cfg.Timezone).Zone()
+	_, jul := time.Date(year, time.July, 1, 0, 0, 0, 0, cfg.Timezone).Zone()
+	return min(jan, jul)
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(fmt.Errorf("%s must be a duration such as 30s: %w", key, err))
+	}
+	return d
+}
+
+// getConfig exposes the display settings so every frontend formats amounts
+// and dates the same way.
+func getConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"currency":            cfg.Currency,
+		"locale":              cfg.Locale,
+		"date_format":         cfg.DateFormat,
+		"timezone":            cfg.Timezone.String(),
+		"uncategorized_label": cfg.UncategorizedLabel,
+	})
+}
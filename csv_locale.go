@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// numberFormat describes how an imported CSV writes its fields and amounts:
+// US-style files use "," between fields and "1,234.56", while most of
+// Europe uses ";" and "1.234,56".
+type numberFormat struct {
+	Delimiter rune
+	Decimal   rune
+	Thousands rune
+}
+
+var (
+	pointDecimal = numberFormat{Delimiter: ',', Decimal: '.', Thousands: ','}
+	commaDecimal = numberFormat{Delimiter: ';', Decimal: ',', Thousands: '.'}
+)
+
+// commaDecimalLanguages are the locale languages that write a decimal
+// comma. Anything else is treated as using a decimal point.
+var commaDecimalLanguages = map[string]bool{
+	"cs": true, "da": true, "de": true, "es": true, "fi": true, "fr": true,
+	"id": true, "it": true, "nb": true, "nl": true, "pl": true, "pt": true,
+	"ru": true, "sv": true, "tr": true, "uk": true,
+}
+
+// localeNumberFormat is the import format implied by a locale such as
+// "de-DE".
+func localeNumberFormat(locale string) numberFormat {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if commaDecimalLanguages[lang] {
+		return commaDecimal
+	}
+	return pointDecimal
+}
+
+// parseNumberFormat starts from the LOCALE default and applies the
+// ?delimiter= (a single character, or "tab") and ?decimal= (point or comma)
+// overrides.
+func parseNumberFormat(delimiter, decimal string) (numberFormat, error) {
+	f := localeNumberFormat(cfg.Locale)
+	switch decimal {
+	case "":
+	case "point":
+		f.Decimal, f.Thousands = '.', ','
+	case "comma":
+		f.Decimal, f.Thousands = ',', '.'
+	default:
+		return f, errors.New("decimal must be point or comma")
+	}
+	switch {
+	case delimiter == "":
+	case delimiter == "tab":
+		f.Delimiter = '\t'
+	case utf8.RuneCountInString(delimiter) == 1:
+		f.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	default:
+		return f, errors.New(`delimiter must be a single character or "tab"`)
+	}
+	if f.Delimiter == f.Decimal {
+		return f, errors.New("delimiter and decimal separator must differ")
+	}
+	return f, nil
+}
+
+// amount rewrites an amount in this format, such as "1.234,56", in the
+// canonical form parseMoney reads, "1234.56". Spaces, which some locales
+// group thousands with, are dropped as well. Group separators are only
+// accepted between groups of three digits, so "12,5" read with a decimal
+// point is an error rather than 125.
+func (f numberFormat) amount(s string) (string, error) {
+	var b strings.Builder
+	digits := 0      // digits since the start of the number or the last separator
+	grouped := false // a separator was seen and its group isn't complete yet
+	fraction := false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == f.Thousands, r == ' ', r == '\u00a0', r == '\u202f':
+			if digits == 0 && r != f.Thousands {
+				continue
+			}
+			if fraction || digits == 0 || digits > 3 || (grouped && digits != 3) {
+				return "", groupingError(s)
+			}
+			grouped, digits = true, 0
+		case r >= '0' && r <= '9':
+			digits++
+			b.WriteRune(r)
+		default:
+			if grouped && digits != 3 {
+				return "", groupingError(s)
+			}
+			grouped = false
+			if r == f.Decimal {
+				fraction = true
+				r = '.'
+			}
+			b.WriteRune(r)
+		}
+	}
+	if grouped && digits != 3 {
+		return "", groupingError(s)
+	}
+	return b.String(), nil
+}
+
+func groupingError(amount string) error {
+	return fmt.Errorf("amount %q: digit groups must be three digits long", amount)
+}
+
+// localDateLayouts are the date forms without an offset that imports
+// accept, read in the import's time zone.
+var localDateLayouts = []string{"2006-01-02", "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+// importDate rewrites a date without an offset, such as "2024-01-31", as
+// the RFC 3339 UTC instant it names in loc. Dates that already carry an
+// offset, and anything unparseable, are returned unchanged for the decoder
+// to accept or reject.
+func importDate(s string, loc *time.Location) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range localDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// normalizeCSV re-encodes r, written in format f, as a comma-separated CSV
+// with canonical amounts and dates, so the rest of the import can decode it
+// with gocsv's defaults. Dates without an offset are read in loc. The
+// returned reader must be closed once decoding stops.
+func normalizeCSV(r io.Reader, f numberFormat, loc *time.Location) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		in := csv.NewReader(r)
+		in.Comma = f.Delimiter
+		in.FieldsPerRecord = -1
+		out := csv.NewWriter(pw)
+
+		amountColumn, dateColumn := -1, -1
+		for line := 1; ; line++ {
+			record, err := in.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if line == 1 {
+				for i, name := range record {
+					switch strings.TrimSpace(name) {
+					case "amount":
+						amountColumn = i
+					case "date":
+						dateColumn = i
+					}
+				}
+			} else {
+				if amountColumn >= 0 && amountColumn < len(record) {
+					if record[amountColumn], err = f.amount(record[amountColumn]); err != nil {
+						pw.CloseWithError(fmt.Errorf("line %d: %w", line, err))
+						return
+					}
+				}
+				if dateColumn >= 0 && dateColumn < len(record) {
+					record[dateColumn] = importDate(record[dateColumn], loc)
+				}
+			}
+			if err := out.Write(record); err != nil {
+				pw.CloseWithError(fmt.Errorf("line %d: %w", line, err))
+				return
+			}
+		}
+		out.Flush()
+		pw.CloseWithError(out.Error())
+	}()
+	return pr
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestImportNumberFormats checks that US and European files import the
+// same amounts.
+func TestImportNumberFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		csv   string
+	}{
+		{"US", "", "date,amount,category,description,type\n" +
+			`2024-03-01,"1,234.56",Salary,March,income` + "\n" +
+			`2024-03-02,-12.5,Food,Lunch,expense` + "\n"},
+		{"EU", "?delimiter=%3B&decimal=comma", "date;amount;category;description;type\n" +
+			"2024-03-01;1.234,56;Salary;March;income\n" +
+			"2024-03-02;-12,5;Food;Lunch;expense\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			body, contentType := uploadBody(t, "import.csv", []byte(tt.csv))
+			w := serve(http.MethodPost, "/api/transactions/import", "/api/transactions/import"+tt.query, contentType, body, importTransactions)
+			if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+				t.Fatalf("got %d: %s", w.Code, w.Body)
+			}
+
+			var amounts []Money
+			rows, err := db.Query("SELECT amount_cents FROM transactions ORDER BY id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var m Money
+				if err := rows.Scan(&m); err != nil {
+					t.Fatal(err)
+				}
+				amounts = append(amounts, m)
+			}
+			if len(amounts) != 2 || amounts[0] != 123456 || amounts[1] != -1250 {
+				t.Errorf("got amounts %v, want [123456 -1250]", amounts)
+			}
+		})
+	}
+}
+
+// TestParseNumberFormatClash checks that a delimiter equal to the decimal
+// separator is rejected, whichever character it is.
+func TestParseNumberFormatClash(t *testing.T) {
+	for _, tt := range []struct{ delimiter, decimal string }{
+		{",", "comma"},
+		{".", "point"},
+	} {
+		if f, err := parseNumberFormat(tt.delimiter, tt.decimal); err == nil {
+			t.Errorf("delimiter %q, decimal %s: got %+v, want an error", tt.delimiter, tt.decimal, f)
+		}
+	}
+	if _, err := parseNumberFormat(";", "comma"); err != nil {
+		t.Errorf("delimiter \";\", decimal comma: %v", err)
+	}
+}
+
+// TestNumberFormatGrouping checks that a thousands separator is only
+// accepted between groups of three digits.
+func TestNumberFormatGrouping(t *testing.T) {
+	for _, tt := range []struct {
+		format numberFormat
+		in     string
+		want   string // "" for an error
+	}{
+		{pointDecimal, "1,234.56", "1234.56"},
+		{pointDecimal, "-1,234,567", "-1234567"},
+		{pointDecimal, "12.5", "12.5"},
+		{pointDecimal, "12,5", ""},
+		{pointDecimal, "1,2345.00", ""},
+		{pointDecimal, "1234,567", ""},
+		{pointDecimal, ",123", ""},
+		{pointDecimal, "1.234,5", ""},
+		{commaDecimal, "1.234,56", "1234.56"},
+		{commaDecimal, "1 234,56", "1234.56"},
+		{commaDecimal, "1.5", ""},
+	} {
+		got, err := tt.format.amount(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: got %q, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	newTestDB(t)
+	body, contentType := uploadBody(t, "import.csv", []byte("date,amount,category,type\n2024-03-02,\"12,5\",Food,expense\n"))
+	w := serve(http.MethodPost, "/api/transactions/import", "/api/transactions/import", contentType, body, importTransactions)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("import of 12,5 with a decimal point: got %d: %s", w.Code, w.Body)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&n); err != nil || n != 0 {
+		t.Errorf("imported %d transactions (%v), want none", n, err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// loggingDriverName is registered alongside "sqlite3" and used instead of
+// it when DEBUG_SQL is set.
+const loggingDriverName = "sqlite3_logged"
+
+func init() {
+	sql.Register(loggingDriverName, loggingDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// driverName picks the SQL driver for cfg.
+func driverName() string {
+	if cfg.DebugSQL {
+		return loggingDriverName
+	}
+	return "sqlite3"
+}
+
+// loggingDriver opens SQLite connections that log every statement with its
+// arguments and duration. Query durations cover execution up to the first
+// row; reading the remaining rows isn't timed.
+type loggingDriver struct {
+	*sqlite3.SQLiteDriver
+}
+
+func (d loggingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return loggingConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// rawSQLiteConn unwraps a driver connection from sql.Conn.Raw, which is a
+// loggingConn when DEBUG_SQL is set.
+func rawSQLiteConn(conn any) *sqlite3.SQLiteConn {
+	if c, ok := conn.(loggingConn); ok {
+		return c.SQLiteConn
+	}
+	return conn.(*sqlite3.SQLiteConn)
+}
+
+// loggingConn embeds the SQLite connection so it keeps every optional
+// driver interface, and overrides the two that run statements.
+type loggingConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	logQuery(query, args, time.Since(start), err)
+	return result, err
+}
+
+func (c loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	logQuery(query, args, time.Since(start), err)
+	return rows, err
+}
+
+func logQuery(query string, args []driver.NamedValue, d time.Duration, err error) {
+	values := make([]any, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	if err != nil {
+		log.Printf("sql: %s %v (%s): %v", compactSQL(query), values, d, err)
+		return
+	}
+	log.Printf("sql: %s %v (%s)", compactSQL(query), values, d)
+}
+
+// compactSQL collapses the indentation of multi-line queries onto one line.
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DuplicateGroup is a set of stored transactions sharing a date, amount and
+// description, oldest ID first.
+type DuplicateGroup struct {
+	Count        int           `json:"count"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// getDuplicates lists groups of transactions with the same date, amount and
+// description.
+func getDuplicates(c *gin.Context) {
+	groups, err := findDuplicates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// deleteDuplicates removes the extra copies in every duplicate group, in a
+// single database transaction. It requires ?resolve=keep_first, which keeps
+// the transaction with the lowest ID, so the strategy is explicit.
+func deleteDuplicates(c *gin.Context) {
+	if c.Query("resolve") != "keep_first" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pass resolve=keep_first to delete all but the first of each duplicate group"})
+		return
+	}
+
+	groups, err := findDuplicates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var extras []*Transaction
+	for _, g := range groups {
+		for i := range g.Transactions[1:] {
+			extras = append(extras, &g.Transactions[i+1])
+		}
+	}
+	if err := checkPeriodsOpen(extras...); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	var keys []string
+	err = withTx(func(tx *sql.Tx) error {
+		for _, t := range extras {
+			for _, query := range []string{
+				"DELETE FROM transactions WHERE id = ?",
+				"DELETE FROM allocations WHERE income_id = ?",
+				"DELETE FROM transaction_tags WHERE transaction_id = ?",
+			} {
+				if _, err := tx.Exec(query, t.ID); err != nil {
+					return err
+				}
+			}
+			k, err := deleteAttachmentRows(tx, "transaction_id = ?", t.ID)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k...)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deleteAttachmentFiles(c.Request.Context(), keys)
+
+	c.JSON(http.StatusOK, gin.H{"groups": len(groups), "deleted": len(extras)})
+}
+
+func findDuplicates() ([]DuplicateGroup, error) {
+	transactions, err := queryTransactions("transactions", `
+		WHERE (date, amount_cents, description) IN (
+			SELECT date, amount_cents, description
+			FROM transactions
+			GROUP BY date, amount_cents, description
+			HAVING COUNT(*) > 1
+		)
+		ORDER BY date, amount_cents, description, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []DuplicateGroup{}
+	for i, t := range transactions {
+		if i > 0 {
+			prev := transactions[i-1]
+			if prev.Date.Equal(t.Date) && prev.Amount == t.Amount && prev.Description == t.Description {
+				g := &groups[len(groups)-1]
+				g.Transactions = append(g.Transactions, t)
+				g.Count++
+				continue
+			}
+		}
+		groups = append(groups, DuplicateGroup{Count: 1, Transactions: []Transaction{t}})
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelope is the shape every JSON response takes when RESPONSE_ENVELOPE is
+// enabled, for clients built against that convention.
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   any             `json:"error"`
+}
+
+// envelopeMiddleware wraps JSON responses in an envelope. Data holds the
+// original body on success; on failure Error holds its "error" message, or
+// the whole body if it has none, and validation "fields" are kept alongside.
+// Other content types, such as CSV downloads and event streams, pass
+// through untouched.
+func envelopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.ResponseEnvelope {
+			c.Next()
+			return
+		}
+
+		w := &envelopeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if !w.buffering {
+			return
+		}
+
+		status := w.Status()
+		body := w.body.Bytes()
+		var out any = envelope{Success: status < 400, Data: body, Error: nil}
+		if status >= 400 {
+			var fields struct {
+				Error  any             `json:"error"`
+				Fields json.RawMessage `json:"fields,omitempty"`
+			}
+			if err := json.Unmarshal(body, &fields); err != nil || fields.Error == nil {
+				fields.Error = json.RawMessage(body)
+			}
+			out = struct {
+				envelope
+				Fields json.RawMessage `json:"fields,omitempty"`
+			}{envelope{Success: false, Data: json.RawMessage("null"), Error: fields.Error}, fields.Fields}
+		}
+
+		wrapped, err := json.Marshal(out)
+		if err != nil {
+			c.Error(err)
+			wrapped = body
+		}
+		w.ResponseWriter.Write(wrapped)
+	}
+}
+
+// envelopeWriter holds back JSON bodies so they can be wrapped once the
+// handler is done, and writes everything else straight through.
+type envelopeWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	buffering bool
+	decided   bool
+}
+
+func (w *envelopeWriter) decide() {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
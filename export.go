@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocarina/gocsv"
+)
+
+// exportAll bundles every transaction together with the monthly and
+// category summaries into a single ZIP archive, as a complete backup.
+func exportAll(c *gin.Context) {
+	transactions, err := queryTransactions("transactions", "ORDER BY id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	monthly, err := queryMonthlySummaries("", "", 0, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	categories, err := queryCategorySummary(transactionFilter{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		rows any
+	}{
+		{"transactions.csv", &transactions},
+		{"monthly-summary.csv", &monthly},
+		{"category-summary.csv", &categories},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := gocsv.Marshal(f.rows, w); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "finance-export-" + time.Now().Format("2006-01-02") + ".zip"
+	c.Header("Content-Disposition", "attachment;filename="+filename)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// importBackup replaces every transaction with the contents of a ZIP made
+// by exportAll. Only transactions.csv is read since the summaries are
+// derived from it. Transaction IDs are kept, and the swap happens in one
+// database transaction so a bad archive leaves the existing data untouched.
+// The replaced transactions' tags, allocations and attachments are dropped.
+func importBackup(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pass confirm=true to replace all transactions"})
+		return
+	}
+	if err := checkNoClosedTransactions(); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	zr, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not a valid ZIP archive: " + err.Error()})
+		return
+	}
+	f, err := zr.Open("transactions.csv")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive does not contain transactions.csv"})
+		return
+	}
+	defer f.Close()
+
+	var transactions []*Transaction
+	if err := gocsv.Unmarshal(f, &transactions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for i, t := range transactions {
+		if err := validateTransaction(t); err != nil {
+			respondImportError(c, fmt.Errorf("transactions.csv line %d: %w", i+2, err))
+			return
+		}
+	}
+	if err := checkPeriodsOpen(transactions...); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	var keys []string
+	err = withTx(func(tx *sql.Tx) error {
+		// Tags, allocations and attachments are keyed by transaction ID
+		// and don't survive the restore, so they go first, while the
+		// rows they belong to can still be told apart from archived ones.
+		for _, query := range []string{
+			"DELETE FROM transaction_tags WHERE transaction_id IN (SELECT id FROM transactions)",
+			"DELETE FROM allocations WHERE income_id IN (SELECT id FROM transactions)",
+		} {
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		var err error
+		keys, err = deleteAttachmentRows(tx, "transaction_id IN (SELECT id FROM transactions)")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM transactions"); err != nil {
+			return err
+		}
+		if err := resolveAccounts(tx, transactions, true); err != nil {
+			return err
+		}
+		for _, t := range transactions {
+			_, err := tx.Exec(
+				"INSERT INTO transactions (id, date, amount_cents, category, description, type, account_id, status, budget_category, flagged, reimbursed, context) VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)",
+				t.ID, t.Date, t.Amount, t.Category, t.Description, t.Type, t.AccountID, t.Status, t.BudgetCategory, t.Flagged, t.Reimbursed, t.Context,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deleteAttachmentFiles(c.Request.Context(), keys)
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(transactions)})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestImportBackupDropsLinkedRows checks that restoring a backup drops the
+// replaced transactions' tags, allocations and attachments but leaves
+// those of archived transactions alone.
+func TestImportBackupDropsLinkedRows(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Now().AddDate(-5, 0, 0), Amount: 90000, Category: "Salary", Type: TypeIncome})
+	mustInsert(t, Transaction{Date: time.Now(), Amount: 120000, Category: "Salary", Type: TypeIncome})
+	for _, id := range []int{1, 2} {
+		attach(t, id, fmt.Sprint("key", id))
+		for _, q := range []string{
+			"INSERT INTO transaction_tags (transaction_id, tag) VALUES (?, 'work')",
+			"INSERT INTO allocations (income_id, bucket, amount_cents) VALUES (?, 'savings', 100)",
+		} {
+			if _, err := db.Exec(q, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+
+	export := serve(http.MethodGet, "/api/export", "/api/export", "", "", exportAll)
+	if export.Code != http.StatusOK {
+		t.Fatalf("export: got %d: %s", export.Code, export.Body)
+	}
+	body, contentType := uploadBody(t, "backup.zip", export.Body.Bytes())
+	w := serve(http.MethodPost, "/api/import/backup", "/api/import/backup?confirm=true", contentType, body, importBackup)
+	if w.Code != http.StatusOK {
+		t.Fatalf("import: got %d: %s", w.Code, w.Body)
+	}
+
+	for _, q := range []string{
+		"SELECT transaction_id FROM transaction_tags",
+		"SELECT income_id FROM allocations",
+		"SELECT transaction_id FROM attachments",
+	} {
+		var ids []int
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if len(ids) != 1 || ids[0] != 1 {
+			t.Errorf("%s: got IDs %v, want only the archived 1", q, ids)
+		}
+	}
+	if _, err := storage.Open(context.Background(), "key2"); err != errObjectNotFound {
+		t.Errorf("replaced transaction's file: got %v, want errObjectNotFound", err)
+	}
+	if r, err := storage.Open(context.Background(), "key1"); err != nil {
+		t.Errorf("archived transaction's file: %v", err)
+	} else {
+		r.Close()
+	}
+}
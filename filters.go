@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transactionFilter holds the query-string filters shared by the endpoints
+// that read transactions. Zero values mean "no restriction".
+type transactionFilter struct {
+	// From and To bound the date, inclusive, on the local calendar.
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Category string `json:"category"`
+	Type     string `json:"type"`
+
+	// Status restricts results to pending or cleared transactions;
+	// cleared_only=true is shorthand for status=cleared.
+	Status string `json:"status"`
+
+	// Search matches transactions whose description contains it,
+	// case-insensitively.
+	Search string `json:"q"`
+
+	// Tag restricts results to transactions carrying it.
+	Tag string `json:"tag"`
+
+	// Context restricts results to business or personal transactions.
+	Context string `json:"context"`
+
+	// IDFrom and IDTo bound transaction IDs inclusively, for reprocessing
+	// a contiguous batch such as one import.
+	IDFrom int `json:"id_from"`
+	IDTo   int `json:"id_to"`
+
+	// Flagged, when set, restricts results to flagged or unflagged
+	// transactions.
+	Flagged *bool `json:"flagged"`
+
+	// Reimbursed, when set, restricts results to reimbursed or
+	// unreimbursed transactions.
+	Reimbursed *bool `json:"reimbursed"`
+
+	// Future restricts results to transactions dated after today.
+	Future bool `json:"future"`
+
+	// IncludeArchived widens the query to archived transactions.
+	IncludeArchived bool `json:"-"`
+}
+
+func parseTransactionFilter(c *gin.Context) (transactionFilter, error) {
+	f := transactionFilter{
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Category: c.Query("category"),
+		Type:     c.Query("type"),
+		Status:   c.Query("status"),
+		Search:   c.Query("q"),
+		Tag:      c.Query("tag"),
+		Context:  c.Query("context"),
+		Future:   c.Query("future") == "true",
+
+		IncludeArchived: c.Query("include_archived") == "true",
+	}
+	if c.Query("cleared_only") == "true" {
+		f.Status = StatusCleared
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"id_from", &f.IDFrom}, {"id_to", &f.IDTo}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return f, fmt.Errorf("%s must be a positive integer", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	if v := c.Query("flagged"); v != "" {
+		flagged, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("flagged must be true or false")
+		}
+		f.Flagged = &flagged
+	}
+	if v := c.Query("reimbursed"); v != "" {
+		reimbursed, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("reimbursed must be true or false")
+		}
+		f.Reimbursed = &reimbursed
+	}
+	return f, f.validate()
+}
+
+// validate checks a filter built from a query string or a request body and
+// normalizes its free-text fields.
+func (f *transactionFilter) validate() error {
+	for _, p := range []struct {
+		name  string
+		value string
+	}{{"from", f.From}, {"to", f.To}} {
+		if p.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", p.value); err != nil {
+			return fmt.Errorf("%s must be a date in YYYY-MM-DD format", p.name)
+		}
+	}
+	if f.From != "" && f.To != "" && f.From > f.To {
+		return fmt.Errorf("from must not be after to")
+	}
+	if f.IDFrom < 0 || f.IDTo < 0 {
+		return fmt.Errorf("id_from and id_to must be positive")
+	}
+	if f.IDFrom != 0 && f.IDTo != 0 && f.IDFrom > f.IDTo {
+		return fmt.Errorf("id_from must not be greater than id_to")
+	}
+	if f.Status != "" && !isTransactionStatus(f.Status) {
+		return fmt.Errorf("status must be %s or %s", StatusPending, StatusCleared)
+	}
+	if f.Context != "" && !isTransactionContext(f.Context) {
+		return fmt.Errorf("context must be %s or %s", ContextBusiness, ContextPersonal)
+	}
+	f.Search = strings.TrimSpace(f.Search)
+	f.Tag = strings.ToLower(strings.TrimSpace(f.Tag))
+	return nil
+}
+
+// table is the FROM expression to query: the live transactions table, or it
+// combined with the archive. Either way it is aliased as transactions.
+func (f transactionFilter) table() string {
+	if f.IncludeArchived {
+		return "(SELECT * FROM transactions UNION ALL SELECT * FROM archived_transactions) AS transactions"
+	}
+	return "transactions"
+}
+
+// where renders the filter as a SQL WHERE clause (empty when unfiltered)
+// along with its positional arguments.
+func (f transactionFilter) where() (string, []any) {
+	conds, args := f.conditions()
+	return whereClause(conds), args
+}
+
+// conditions returns the filter's SQL conditions for callers that need to
+// AND in conditions of their own before calling whereClause.
+func (f transactionFilter) conditions() ([]string, []any) {
+	var conds []string
+	var args []any
+	if f.From != "" {
+		conds = append(conds, "date(date, ?) >= ?")
+		args = append(args, tzModifier(), f.From)
+	}
+	if f.To != "" {
+		conds = append(conds, "date(date, ?) <= ?")
+		args = append(args, tzModifier(), f.To)
+	}
+	if f.Category != "" {
+		conds = append(conds, "category = ?")
+		args = append(args, f.Category)
+	}
+	if f.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Context != "" {
+		conds = append(conds, "context = ?")
+		args = append(args, f.Context)
+	}
+	if f.Tag != "" {
+		conds = append(conds, "id IN (SELECT transaction_id FROM transaction_tags WHERE tag = ?)")
+		args = append(args, f.Tag)
+	}
+	if f.Search != "" {
+		conds = append(conds, "description LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+	}
+	if f.IDFrom != 0 {
+		conds = append(conds, "id >= ?")
+		args = append(args, f.IDFrom)
+	}
+	if f.IDTo != 0 {
+		conds = append(conds, "id <= ?")
+		args = append(args, f.IDTo)
+	}
+	if f.Flagged != nil {
+		conds = append(conds, "flagged = ?")
+		args = append(args, *f.Flagged)
+	}
+	if f.Reimbursed != nil {
+		conds = append(conds, "reimbursed = ?")
+		args = append(args, *f.Reimbursed)
+	}
+	if f.Future {
+		conds = append(conds, "date(date, ?) > date('now', ?)")
+		args = append(args, tzModifier(), tzModifier())
+	}
+	return conds, args
+}
+
+// likeEscaper escapes LIKE wildcards so a search matches them literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, " AND ")
+}
+
+// monthParam reads a YYYY-MM query parameter, defaulting to the current
+// month when it is absent.
+func monthParam(c *gin.Context, name string) (string, error) {
+	v := c.Query(name)
+	if v == "" {
+		return localMonth(time.Now()), nil
+	}
+	if !isMonth(v) {
+		return "", fmt.Errorf("%s must be a month in YYYY-MM format", name)
+	}
+	return v, nil
+}
+
+func isMonth(s string) bool {
+	_, err := time.Parse("2006-01", s)
+	return err == nil
+}
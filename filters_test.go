@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestDateFilterLocalCalendar checks that from and to compare the local
+// date, so a transaction just after local midnight belongs to that day even
+// though it is still the previous day in UTC.
+func TestDateFilterLocalCalendar(t *testing.T) {
+	newTestDB(t)
+	previous := cfg.Timezone
+	cfg.Timezone = time.FixedZone("", 10*60*60)
+	t.Cleanup(func() { cfg.Timezone = previous })
+
+	mustInsert(t, Transaction{Date: time.Date(2024, 3, 1, 0, 30, 0, 0, cfg.Timezone).UTC(), Amount: -500, Category: "Food", Type: TypeExpense})
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"?from=2024-03-01&to=2024-03-01", 1},
+		{"?from=2024-02-29&to=2024-02-29", 0},
+	} {
+		w := serve(http.MethodGet, "/api/transactions", "/api/transactions"+tt.query, "", "", getTransactions)
+		var got []Transaction
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%d: %s", w.Code, w.Body)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: got %d transactions, want %d", tt.query, len(got), tt.want)
+		}
+	}
+}
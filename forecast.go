@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forecastTrailingMonths is how many complete past months the non-recurring
+// average is taken over.
+const forecastTrailingMonths = 3
+
+type ForecastMonth struct {
+	Month            string `json:"month"`
+	RecurringIncome  Money  `json:"recurring_income"`
+	RecurringExpense Money  `json:"recurring_expense"`
+	OtherIncome      Money  `json:"other_income"`
+	OtherExpense     Money  `json:"other_expense"`
+	Income           Money  `json:"income"`
+	Expense          Money  `json:"expense"`
+	Net              Money  `json:"net"`
+	EndingBalance    Money  `json:"ending_balance"`
+}
+
+// getForecast projects income, expense and balance for the rest of the
+// current month and the next ?months= calendar months (default 3).
+// Recurring rules contribute their scheduled occurrences; everything else is
+// the monthly average, over the last few complete months, of categories that
+// have no recurring rule of that type.
+func getForecast(c *gin.Context) {
+	months, err := strconv.Atoi(c.DefaultQuery("months", "3"))
+	if err != nil || months < 1 || months > 24 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "months must be between 1 and 24"})
+		return
+	}
+
+	recurring, err := queryRecurring()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recurringCategory := map[string]bool{}
+	for _, r := range recurring {
+		recurringCategory[r.Type+"/"+r.Category] = true
+	}
+
+	// Month boundaries are the local calendar's, expressed as UTC midnights
+	// like the dates recurring rules are scheduled on.
+	now := time.Now().In(time.FixedZone("", tzOffset()))
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	trailingStart := thisMonth.AddDate(0, -forecastTrailingMonths, 0)
+
+	rows, err := db.Query(`
+		SELECT type, category, SUM(ABS(amount_cents))
+		FROM transactions
+		WHERE strftime('%Y-%m', datetime(date, ?)) >= ? AND strftime('%Y-%m', datetime(date, ?)) < ?
+		GROUP BY type, category
+	`, tzModifier(), trailingStart.Format("2006-01"), tzModifier(), localMonth(now))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var otherIncome, otherExpense Money
+	for rows.Next() {
+		var typ, category string
+		var total Money
+		if err := rows.Scan(&typ, &category, &total); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if recurringCategory[typ+"/"+category] {
+			continue
+		}
+		switch typ {
+		case TypeIncome:
+			otherIncome += total
+		case TypeRefund:
+			otherExpense -= total
+		default:
+			otherExpense += total
+		}
+	}
+	rows.Close()
+	otherIncome = divideMoney(otherIncome, forecastTrailingMonths)
+	otherExpense = divideMoney(otherExpense, forecastTrailingMonths)
+
+	var balance Money
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN type IN (?, ?) THEN ABS(amount_cents) ELSE -ABS(amount_cents) END), 0)
+		FROM (
+			SELECT type, amount_cents, date FROM transactions
+			UNION ALL SELECT type, amount_cents, date FROM archived_transactions
+		)
+		WHERE date(date, ?) <= ?
+	`, TypeIncome, TypeRefund, tzModifier(), now.Format("2006-01-02")).Scan(&balance)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	startingBalance := balance
+
+	// The current month comes first with only the recurring occurrences
+	// still due after today, since the rest of it is already recorded.
+	forecast := make([]ForecastMonth, 0, months+1)
+	for i := 0; i <= months; i++ {
+		from := thisMonth.AddDate(0, i, 0)
+		to := from.AddDate(0, 1, 0)
+		m := ForecastMonth{Month: from.Format("2006-01"), OtherIncome: otherIncome, OtherExpense: otherExpense}
+		if i == 0 {
+			from = tomorrow
+			m.OtherIncome, m.OtherExpense = 0, 0
+		}
+		for _, r := range recurring {
+			amount := r.Amount.Abs() * Money(r.occurrencesIn(from, to))
+			switch r.Type {
+			case TypeIncome:
+				m.RecurringIncome += amount
+			case TypeRefund:
+				m.RecurringExpense -= amount
+			default:
+				m.RecurringExpense += amount
+			}
+		}
+		m.Income = m.RecurringIncome + m.OtherIncome
+		m.Expense = m.RecurringExpense + m.OtherExpense
+		m.Net = m.Income - m.Expense
+		balance += m.Net
+		m.EndingBalance = balance
+		forecast = append(forecast, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"starting_balance": startingBalance,
+		"months":           forecast,
+		"basis": fmt.Sprintf(
+			"Recurring rules contribute their scheduled occurrences. Other income and expense are the monthly average "+
+				"of %s to %s for categories without a recurring rule of the same type. The current month "+
+				"only adds the recurring occurrences still due after today. Balances start from the net of all "+
+				"transactions recorded up to today, archived ones included.",
+			trailingStart.Format("2006-01"), thisMonth.AddDate(0, -1, 0).Format("2006-01"),
+		),
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestForecast checks that the starting balance counts archived
+// transactions but not future-dated ones, that the trailing average groups
+// by local month and that recurring occurrences still due this month are
+// projected.
+func TestForecast(t *testing.T) {
+	newTestDB(t)
+	previous := cfg.Timezone
+	cfg.Timezone = time.FixedZone("", -10*60*60)
+	t.Cleanup(func() { cfg.Timezone = previous })
+
+	now := time.Now().In(cfg.Timezone)
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, cfg.Timezone)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	mustInsert(t, Transaction{Date: now.AddDate(-5, 0, 0), Amount: 10000, Category: "Salary", Type: TypeIncome})
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+	// The last evening of the previous local month is already this month in UTC.
+	mustInsert(t, Transaction{Date: thisMonth.Add(-4 * time.Hour), Amount: -9000, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: now.AddDate(0, 0, 3), Amount: -3000, Category: "Food", Type: TypeExpense})
+	if _, err := db.Exec(`
+		INSERT INTO recurring_transactions (description, category, type, amount_cents, frequency, start_date)
+		VALUES ('Rent', 'Rent', ?, -2000, 'monthly', ?)
+	`, TypeExpense, tomorrow); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(http.MethodGet, "/api/forecast", "/api/forecast?months=2", "", "", getForecast)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var got struct {
+		StartingBalance Money           `json:"starting_balance"`
+		Months          []ForecastMonth `json:"months"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.StartingBalance != 1000 {
+		t.Errorf("starting_balance = %s, want 10.00", got.StartingBalance)
+	}
+	if len(got.Months) != 3 {
+		t.Fatalf("got %d months, want the current one and 2 more", len(got.Months))
+	}
+	var rentDue Money
+	if tomorrow.Month() == now.Month() {
+		rentDue = 2000
+	}
+	if m := got.Months[0]; m.Month != localMonth(now) || m.RecurringExpense != rentDue || m.OtherExpense != 0 {
+		t.Errorf("current month = %+v, want %s with recurring expense %s and nothing else", m, localMonth(now), rentDue)
+	}
+	if m := got.Months[1]; m.OtherExpense != 3000 || m.RecurringExpense != 2000 {
+		t.Errorf("next month = %+v, want other expense 30.00 and recurring expense 20.00", m)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Goal is a savings target. Progress is measured as cumulative net savings
+// (income minus expenses) from StartDate onwards.
+type Goal struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Target     Money     `json:"target_amount"`
+	TargetDate time.Time `json:"target_date"`
+	StartDate  time.Time `json:"start_date"`
+}
+
+type GoalStatus struct {
+	Goal
+	Saved           Money   `json:"saved"`
+	Remaining       Money   `json:"remaining"`
+	Progress        float64 `json:"progress"`
+	MonthsLeft      int     `json:"months_left"`
+	RequiredMonthly Money   `json:"required_monthly"`
+}
+
+func getGoals(c *gin.Context) {
+	rows, err := db.Query("SELECT id, name, target_cents, target_date, start_date FROM goals ORDER BY target_date")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		var g Goal
+		if err := rows.Scan(&g.ID, &g.Name, &g.Target, &g.TargetDate, &g.StartDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		goals = append(goals, g)
+	}
+
+	c.JSON(http.StatusOK, goals)
+}
+
+func addGoal(c *gin.Context) {
+	var g Goal
+	if err := c.ShouldBindJSON(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateGoal(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO goals (name, target_cents, target_date, start_date) VALUES (?, ?, ?, ?)",
+		g.Name, g.Target, g.TargetDate, g.StartDate,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	g.ID = int(id)
+	c.JSON(http.StatusCreated, g)
+}
+
+func updateGoal(c *gin.Context) {
+	var g Goal
+	if err := c.ShouldBindJSON(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateGoal(&g); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec(
+		"UPDATE goals SET name = ?, target_cents = ?, target_date = ?, start_date = ? WHERE id = ?",
+		g.Name, g.Target, g.TargetDate, g.StartDate, c.Param("id"),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, g)
+}
+
+func deleteGoal(c *gin.Context) {
+	_, err := db.Exec("DELETE FROM goals WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getGoalStatus reports how far a goal has come and how much needs to be
+// saved each remaining month to reach it by the target date.
+func getGoalStatus(c *gin.Context) {
+	var s GoalStatus
+	err := db.QueryRow(
+		"SELECT id, name, target_cents, target_date, start_date FROM goals WHERE id = ?", c.Param("id"),
+	).Scan(&s.ID, &s.Name, &s.Target, &s.TargetDate, &s.StartDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN type = ? THEN amount_cents WHEN type = ? THEN ABS(amount_cents) ELSE -ABS(amount_cents) END), 0)
+		FROM transactions
+		WHERE date(date) >= date(?)
+	`, TypeIncome, TypeRefund, s.StartDate).Scan(&s.Saved)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.Remaining = max(s.Target-s.Saved, 0)
+	s.Progress = math.Round(float64(s.Saved)/float64(s.Target)*10000) / 100
+	s.MonthsLeft = monthsUntil(time.Now(), s.TargetDate)
+	if s.Remaining > 0 {
+		// Round up so that saving the required amount every month is
+		// always enough.
+		months := Money(max(s.MonthsLeft, 1))
+		s.RequiredMonthly = (s.Remaining + months - 1) / months
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// monthsUntil counts the calendar months from now until target, so a
+// December target seen in October leaves two months.
+func monthsUntil(now, target time.Time) int {
+	months := (target.Year()-now.Year())*12 + int(target.Month()) - int(now.Month())
+	return max(months, 0)
+}
+
+func validateGoal(g *Goal) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return errors.New("name is required")
+	}
+	if g.Target <= 0 {
+		return errors.New("target_amount must be positive")
+	}
+	if g.TargetDate.IsZero() {
+		return errors.New("target_date is required")
+	}
+	if g.StartDate.IsZero() {
+		g.StartDate = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if !g.TargetDate.After(g.StartDate) {
+		return errors.New("target_date must be after start_date")
+	}
+	return nil
+}
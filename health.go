@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getHealth reports whether the process is up and can reach the database.
+func getHealth(c *gin.Context) {
+	if err := db.PingContext(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// getReady additionally requires every migration to have been applied, so a
+// load balancer doesn't route traffic to an instance whose schema is behind.
+func getReady(c *gin.Context) {
+	var version int
+	if err := db.QueryRowContext(c.Request.Context(), "PRAGMA user_version").Scan(&version); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+
+	body := gin.H{"status": "ok", "schema_version": version, "expected_schema_version": len(migrations)}
+	if version != len(migrations) {
+		body["status"] = "migrating"
+		if version > len(migrations) {
+			// The database was migrated by a newer build.
+			body["status"] = "schema_ahead"
+		}
+		c.JSON(http.StatusServiceUnavailable, body)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
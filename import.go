@@ -0,0 +1,305 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocarina/gocsv"
+)
+
+// importProgressEvery controls how often the streaming import reports
+// progress, in rows.
+const importProgressEvery = 100
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func insertTransaction(e execer, t *Transaction) (int64, error) {
+	result, err := e.Exec(
+		"INSERT INTO transactions (date, amount_cents, category, description, type, account_id, status, budget_category, context) VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?)",
+		t.Date, t.Amount, t.Category, t.Description, t.Type, t.AccountID, t.Status, t.BudgetCategory, t.Context,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// parseImportFile reads the CSV uploaded in the "file" form field. An
+// optional account column names the account each row belongs to. A file
+// whose content was imported before is rejected unless ?force=true.
+func parseImportFile(c *gin.Context) ([]*Transaction, importedFile, error) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, importedFile{}, err
+	}
+	defer file.Close()
+
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		return nil, importedFile{}, err
+	}
+	f, err := hashUpload(file, header.Filename)
+	if err != nil {
+		return nil, f, err
+	}
+	if c.Query("force") != "true" {
+		if err := checkNotImported(db, f); err != nil {
+			return nil, f, err
+		}
+	}
+	transactions, err := parseImportCSV(file, opts)
+	return transactions, f, err
+}
+
+// importOptions are the per-request settings shared by every import path.
+type importOptions struct {
+	// DefaultCategory fills in rows whose category is blank, taking
+	// precedence over UNCATEGORIZED_LABEL.
+	DefaultCategory string
+
+	// Format is the file's field delimiter and amount separators.
+	Format numberFormat
+
+	// Location is the zone dates without an offset are read in.
+	Location *time.Location
+}
+
+func parseImportOptions(c *gin.Context) (importOptions, error) {
+	opts := importOptions{
+		DefaultCategory: strings.TrimSpace(c.Query("default_category")),
+		Location:        cfg.ImportTimezone,
+	}
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, errors.New("tz must be a time zone name such as America/Chicago")
+		}
+		opts.Location = loc
+	}
+	var err error
+	opts.Format, err = parseNumberFormat(c.Query("delimiter"), c.Query("decimal"))
+	return opts, err
+}
+
+// errTooManyRows is returned once an import exceeds MAX_IMPORT_ROWS.
+var errTooManyRows = errors.New("import exceeds the maximum number of rows")
+
+// zeroAmountsError reports a file in which so many amounts are zero that
+// the amount column was probably missing or misnamed.
+type zeroAmountsError struct {
+	zero, rows int
+}
+
+func (e zeroAmountsError) Error() string {
+	return fmt.Sprintf("%d of %d rows have a zero amount; check that the file has an amount column holding the transaction amounts", e.zero, e.rows)
+}
+
+// checkZeroAmounts fails when more than IMPORT_MAX_ZERO_AMOUNT_PERCENT of
+// rows have a zero amount.
+func checkZeroAmounts(zero, rows int) error {
+	if zero > 0 && zero*100 > rows*cfg.ImportMaxZeroAmountPercent {
+		return zeroAmountsError{zero, rows}
+	}
+	return nil
+}
+
+// parseImportCSV decodes and validates CSV rows from any source. Rows are
+// counted as they stream in, so an oversized file is rejected without
+// holding more than MAX_IMPORT_ROWS of it in memory. The whole file is read
+// even after an invalid row so that a file of mostly zero amounts is
+// reported as such rather than by its first bad line.
+func parseImportCSV(r io.Reader, opts importOptions) ([]*Transaction, error) {
+	var transactions []*Transaction
+	var invalid error
+	rows, zero := 0, 0
+	err := decodeImportCSV(r, opts, func(line int, t *Transaction) error {
+		rows++
+		if t.Amount == 0 {
+			zero++
+		}
+		if invalid != nil {
+			return nil
+		}
+		if err := validateTransaction(t); err != nil {
+			invalid = fmt.Errorf("line %d: %w", line, err)
+			return nil
+		}
+		transactions = append(transactions, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkZeroAmounts(zero, rows); err != nil {
+		return nil, err
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+	return transactions, nil
+}
+
+// decodeImportCSV calls fn for each decoded row with its line number in the
+// file, after applying the row cap and opts but before any validation.
+func decodeImportCSV(r io.Reader, opts importOptions, fn func(line int, t *Transaction) error) error {
+	normalized := normalizeCSV(r, opts.Format, opts.Location)
+	defer normalized.Close()
+
+	rows := 0
+	return gocsv.UnmarshalToCallbackWithError(normalized, func(t *Transaction) error {
+		if rows >= cfg.MaxImportRows {
+			return errTooManyRows
+		}
+		rows++
+		if strings.TrimSpace(t.Category) == "" {
+			t.Category = opts.DefaultCategory
+		}
+		return fn(rows+1, t)
+	})
+}
+
+// respondImportError answers 422 when rows parsed but failed validation or
+// were mostly zero amounts, 413 when there were too many of them, 409 when
+// the file was imported before, and 400 when the upload itself couldn't be
+// read.
+func respondImportError(c *gin.Context, err error) {
+	if errors.Is(err, errTooManyRows) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("%v (%d); split the file and import it in parts", err, cfg.MaxImportRows),
+		})
+		return
+	}
+	var ierr alreadyImportedError
+	if errors.As(err, &ierr) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var zerr zeroAmountsError
+	if errors.As(err, &zerr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	var verr ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func importTransactions(c *gin.Context) {
+	if c.Query("mode") == "validate" {
+		validateImport(c)
+		return
+	}
+	if files := multiImportFiles(c); len(files) > 0 {
+		importFiles(c, files)
+		return
+	}
+
+	transactions, file, err := parseImportFile(c)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	saveImport(c, transactions, file)
+}
+
+// saveImport inserts parsed rows in a single database transaction and
+// writes the response. file, unless zero, is recorded as imported.
+func saveImport(c *gin.Context, transactions []*Transaction, file importedFile) {
+	if err := checkPeriodsOpen(transactions...); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if err := resolveAccounts(tx, transactions, c.Query("create_accounts") == "true"); err != nil {
+			return err
+		}
+		for _, t := range transactions {
+			if _, err := insertTransaction(tx, t); err != nil {
+				return err
+			}
+		}
+		return recordImported(tx, file)
+	})
+	if errors.Is(err, errUnknownAccount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusCreated)
+}
+
+// importTransactionsStream behaves like importTransactions but reports
+// progress as server-sent events: "progress" events carry
+// {processed, total}, followed by a final "done" or "error" event.
+func importTransactionsStream(c *gin.Context) {
+	transactions, file, err := parseImportFile(c)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	if err := checkPeriodsOpen(transactions...); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	total := len(transactions)
+	processed := 0
+	err = withTx(func(tx *sql.Tx) error {
+		if err := resolveAccounts(tx, transactions, c.Query("create_accounts") == "true"); err != nil {
+			return err
+		}
+
+		var insertErr error
+		clientGone := c.Stream(func(w io.Writer) bool {
+			end := min(processed+importProgressEvery, total)
+			for ; processed < end; processed++ {
+				if _, err := insertTransaction(tx, transactions[processed]); err != nil {
+					insertErr = err
+					return false
+				}
+			}
+			c.SSEvent("progress", gin.H{"processed": processed, "total": total})
+			return processed < total
+		})
+		if insertErr != nil {
+			return insertErr
+		}
+		if clientGone {
+			return c.Request.Context().Err()
+		}
+		return recordImported(tx, file)
+	})
+	switch {
+	case !c.Writer.Written() && errors.Is(err, errUnknownAccount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case !c.Writer.Written() && err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case err != nil:
+		c.SSEvent("error", gin.H{"error": err.Error(), "processed": processed, "total": total})
+	default:
+		c.SSEvent("done", gin.H{"processed": processed, "total": total})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// importedFile identifies an uploaded CSV by the SHA-256 of its content, so
+// the same statement can't be imported twice by mistake.
+type importedFile struct {
+	Hash string
+	Name string
+}
+
+// alreadyImportedError rejects a file whose content was imported before.
+type alreadyImportedError struct {
+	name       string
+	importedAt time.Time
+}
+
+func (e alreadyImportedError) Error() string {
+	return fmt.Sprintf("this file was already imported on %s (as %s); pass force=true to import it again",
+		e.importedAt.Format("2006-01-02"), e.name)
+}
+
+// hashUpload hashes r and rewinds it for parsing.
+func hashUpload(r io.ReadSeeker, name string) (importedFile, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return importedFile{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return importedFile{}, err
+	}
+	return importedFile{Hash: hex.EncodeToString(h.Sum(nil)), Name: name}, nil
+}
+
+// checkNotImported returns an alreadyImportedError if f was imported before.
+func checkNotImported(q queryer, f importedFile) error {
+	rows, err := q.Query("SELECT filename, imported_at FROM imported_files WHERE hash = ?", f.Hash)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return rows.Err()
+	}
+	var e alreadyImportedError
+	if err := rows.Scan(&e.name, &e.importedAt); err != nil {
+		return err
+	}
+	return e
+}
+
+// recordImported remembers f as imported, alongside the rows it added. A
+// forced re-import refreshes the date.
+func recordImported(e execer, f importedFile) error {
+	if f.Hash == "" {
+		return nil
+	}
+	_, err := e.Exec("INSERT OR REPLACE INTO imported_files (hash, filename) VALUES (?, ?)", f.Hash, f.Name)
+	return err
+}
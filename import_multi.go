@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileImportResult reports how one file of a multi-file import went.
+type FileImportResult struct {
+	File     string `json:"file"`
+	Imported int    `json:"imported"`
+	Error    string `json:"error,omitempty"`
+}
+
+// errFilesFailed rolls back a multi-file import in which files failed.
+var errFilesFailed = errors.New("no files were imported; fix the files with errors or pass partial=true to import the rest")
+
+// multiImportFiles returns the files uploaded under file[], or nil when the
+// request isn't a multi-file import.
+func multiImportFiles(c *gin.Context) []*multipart.FileHeader {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil
+	}
+	return form.File["file[]"]
+}
+
+// importFiles imports every file uploaded under file[] in one database
+// transaction and reports on each. By default a failure in any file rolls
+// back all of them; with partial=true only the failing files are skipped.
+// Every file is checked either way so the response lists all the problems.
+func importFiles(c *gin.Context, files []*multipart.FileHeader) {
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	partial := c.Query("partial") == "true"
+	createAccounts := c.Query("create_accounts") == "true"
+	force := c.Query("force") == "true"
+
+	results := make([]FileImportResult, len(files))
+	imported := 0
+	err = withTx(func(tx *sql.Tx) error {
+		failed := 0
+		for i, fh := range files {
+			results[i].File = fh.Filename
+			n, err := importFile(tx, fh, opts, createAccounts, force)
+			if err != nil {
+				results[i].Error = err.Error()
+				failed++
+				continue
+			}
+			results[i].Imported = n
+			imported += n
+		}
+		if failed == len(files) || (failed > 0 && !partial) {
+			return errFilesFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errFilesFailed) {
+		for i := range results {
+			results[i].Imported = 0
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "files": results})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"imported": imported, "files": results})
+}
+
+// importFile imports one uploaded file with importCSVFile.
+func importFile(tx *sql.Tx, fh *multipart.FileHeader, opts importOptions, createAccounts, force bool) (int, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return importCSVFile(tx, f, fh.Filename, opts, createAccounts, force)
+}
+
+// importCSVFile parses a CSV file and inserts its rows inside a savepoint,
+// so a failure undoes only this file. Unless force is set, a file imported
+// before is rejected.
+func importCSVFile(tx *sql.Tx, f io.ReadSeeker, name string, opts importOptions, createAccounts, force bool) (int, error) {
+	file, err := hashUpload(f, name)
+	if err != nil {
+		return 0, err
+	}
+	if !force {
+		if err := checkNotImported(tx, file); err != nil {
+			return 0, err
+		}
+	}
+	transactions, err := parseImportCSV(f, opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkPeriodsOpen(transactions...); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("SAVEPOINT import_file"); err != nil {
+		return 0, err
+	}
+	err = resolveAccounts(tx, transactions, createAccounts)
+	for _, t := range transactions {
+		if err != nil {
+			break
+		}
+		_, err = insertTransaction(tx, t)
+	}
+	if err == nil {
+		err = recordImported(tx, file)
+	}
+	if err != nil {
+		tx.Exec("ROLLBACK TO import_file")
+		tx.Exec("RELEASE import_file")
+		return 0, err
+	}
+	if _, err := tx.Exec("RELEASE import_file"); err != nil {
+		return 0, err
+	}
+	return len(transactions), nil
+}
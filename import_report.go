@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportReport summarises the quality of a CSV without importing it.
+type ImportReport struct {
+	Rows            int      `json:"rows"`
+	Valid           int      `json:"valid"`
+	Invalid         int      `json:"invalid"`
+	Duplicates      int      `json:"duplicates"`
+	InvalidType     int      `json:"invalid_type"`
+	MissingCategory int      `json:"missing_category"`
+	ZeroAmount      int      `json:"zero_amount"`
+	MinDate         *string  `json:"min_date"`
+	MaxDate         *string  `json:"max_date"`
+	Errors          []string `json:"errors"`
+
+	// Warning flags a likely column mapping problem, such as a file of
+	// mostly zero amounts.
+	Warning string `json:"warning,omitempty"`
+}
+
+// maxReportErrors caps the per-line messages an ImportReport carries.
+const maxReportErrors = 20
+
+// validateImport answers POST /api/transactions/import?mode=validate: it
+// checks every row of the upload and reports what an import would do
+// without writing anything. Unlike a real import it keeps going past
+// invalid rows so the counts cover the whole file.
+//
+// A row is a duplicate when its date, amount, category and description
+// match an existing transaction or an earlier row of the same file.
+func validateImport(c *gin.Context) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := ImportReport{Errors: []string{}}
+	var valid []*Transaction
+	err = decodeImportCSV(file, opts, func(line int, t *Transaction) error {
+		report.Rows++
+		if !isTransactionType(t.Type) {
+			report.InvalidType++
+		}
+		if strings.TrimSpace(t.Category) == "" {
+			report.MissingCategory++
+		}
+		if t.Amount == 0 {
+			report.ZeroAmount++
+		}
+		if err := validateTransaction(t); err != nil {
+			report.Invalid++
+			if len(report.Errors) < maxReportErrors {
+				report.Errors = append(report.Errors, fmt.Sprintf("line %d: %v", line, err))
+			}
+			return nil
+		}
+		report.Valid++
+		valid = append(valid, t)
+		return nil
+	})
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	if err := checkZeroAmounts(report.ZeroAmount, report.Rows); err != nil {
+		report.Warning = err.Error()
+	}
+
+	if len(valid) > 0 {
+		minDate, maxDate := valid[0].Date, valid[0].Date
+		for _, t := range valid[1:] {
+			if t.Date.Before(minDate) {
+				minDate = t.Date
+			}
+			if t.Date.After(maxDate) {
+				maxDate = t.Date
+			}
+		}
+		from, to := minDate.UTC().Format("2006-01-02"), maxDate.UTC().Format("2006-01-02")
+		report.MinDate, report.MaxDate = &from, &to
+
+		existing, err := queryTransactions("transactions", "WHERE date(date) >= ? AND date(date) <= ?", from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		seen := map[string]bool{}
+		for i := range existing {
+			seen[duplicateKey(&existing[i])] = true
+		}
+		for _, t := range valid {
+			key := duplicateKey(t)
+			if seen[key] {
+				report.Duplicates++
+			}
+			seen[key] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func duplicateKey(t *Transaction) string {
+	return fmt.Sprintf("%d|%d|%s|%s", t.Date.Unix(), t.Amount, t.Category, strings.TrimSpace(t.Description))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestImportTransactionsStream checks that the streamed import commits and
+// ends with a done event, and that an unknown account is still a 400
+// before any events are sent.
+func TestImportTransactionsStream(t *testing.T) {
+	newTestDB(t)
+	r := gin.New()
+	r.POST("/api/transactions/import/stream", importTransactionsStream)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	post := func(body, contentType string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/api/transactions/import/stream", contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	csv := "date,amount,category,description,type\n"
+	for range 150 {
+		csv += "2024-03-01,-1.00,Food,Lunch,expense\n"
+	}
+	code, events := post(uploadBody(t, "import.csv", []byte(csv)))
+	if !strings.Contains(events, "event:done") {
+		t.Fatalf("got %d: %s", code, events)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 150 {
+		t.Errorf("got %d transactions, want 150", n)
+	}
+
+	code, events = post(uploadBody(t, "accounts.csv", []byte("date,amount,category,type,account\n2024-03-02,-1.00,Food,expense,Nowhere\n")))
+	if code != http.StatusBadRequest {
+		t.Errorf("unknown account: got %d, want 400: %s", code, events)
+	}
+}
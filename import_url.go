@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errURLTooLarge = errors.New("remote file exceeds the size limit")
+
+// importCSVContentTypes are the content types accepted from remote CSV
+// sources. Published Google Sheets serve text/csv.
+var importCSVContentTypes = []string{"text/csv", "text/plain", "application/csv", "application/octet-stream"}
+
+// importTransactionsFromURL fetches a CSV, such as a published Google Sheet,
+// and imports it like an uploaded file.
+func importTransactionsFromURL(c *gin.Context) {
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := checkImportURL(req.URL)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": ValidationError{"url": err.Error()}})
+		return
+	}
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := fetchImportURL(c.Request.Context(), u)
+	if errors.Is(err, errURLTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%v (%d bytes)", err, cfg.ImportURLMaxBytes)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactions, err := parseImportCSV(strings.NewReader(body), opts)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	saveImport(c, transactions, importedFile{})
+}
+
+// checkImportURL only lets through http(s) URLs, restricted to
+// IMPORT_URL_ALLOWED_HOSTS when that is set.
+func checkImportURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return nil, errors.New("must be an absolute URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("must use http or https")
+	}
+	if len(cfg.ImportURLAllowedHosts) > 0 && !slices.Contains(cfg.ImportURLAllowedHosts, strings.ToLower(u.Hostname())) {
+		return nil, fmt.Errorf("host %s is not in the allowed list", u.Hostname())
+	}
+	return u, nil
+}
+
+func fetchImportURL(ctx context.Context, u *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ImportURLTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := importHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", u.Redacted(), resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !slices.Contains(importCSVContentTypes, mediaType) {
+		return "", fmt.Errorf("unexpected content type %q, expected CSV", mediaType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, cfg.ImportURLMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > cfg.ImportURLMaxBytes {
+		return "", errURLTooLarge
+	}
+	return string(data), nil
+}
+
+// importHTTPClient refuses to connect to loopback, private and link-local
+// addresses. Checking at dial time covers redirects and DNS names that
+// resolve to internal hosts, which a check on the URL alone would miss.
+var importHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+					ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+					return fmt.Errorf("refusing to connect to internal address %s", host)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
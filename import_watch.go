@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// importWatchSettle is how long a file must go unmodified before the
+// watcher picks it up, so a file still being copied in isn't read half
+// written.
+const importWatchSettle = 10 * time.Second
+
+// Subdirectories of IMPORT_WATCH_DIR that processed files are moved to.
+const (
+	importWatchArchiveDir = "archive"
+	importWatchFailedDir  = "failed"
+)
+
+// scanImportDir imports every CSV file in IMPORT_WATCH_DIR. Imported files,
+// and files whose content was imported before, are moved to its archive
+// subdirectory; files that fail to import are moved to failed so they
+// aren't retried until fixed and dropped in again.
+func scanImportDir() error {
+	entries, err := os.ReadDir(cfg.ImportWatchDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".csv") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < importWatchSettle {
+			continue
+		}
+
+		dest := importWatchArchiveDir
+		n, err := importWatchedFile(filepath.Join(cfg.ImportWatchDir, e.Name()))
+		var ierr alreadyImportedError
+		switch {
+		case errors.As(err, &ierr):
+			log.Printf("import watch: %s: skipped: %v", e.Name(), err)
+		case err != nil:
+			dest = importWatchFailedDir
+			log.Printf("import watch: %s: failed: %v", e.Name(), err)
+		default:
+			summaryCache.clear()
+			log.Printf("import watch: %s: imported %d transactions", e.Name(), n)
+		}
+		if err := moveWatchedFile(e.Name(), dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importWatchedFile imports the file at path the way an upload with no
+// query parameters would be imported.
+func importWatchedFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	opts := importOptions{Format: localeNumberFormat(cfg.Locale), Location: cfg.ImportTimezone}
+	var n int
+	err = withTx(func(tx *sql.Tx) error {
+		n, err = importCSVFile(tx, f, filepath.Base(path), opts, false, false)
+		return err
+	})
+	return n, err
+}
+
+// moveWatchedFile moves name from IMPORT_WATCH_DIR into its subdir,
+// prefixing a timestamp so a later file of the same name doesn't replace
+// it.
+func moveWatchedFile(name, subdir string) error {
+	dir := filepath.Join(cfg.ImportWatchDir, subdir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, time.Now().Format("20060102-150405")+"-"+name)
+	return os.Rename(filepath.Join(cfg.ImportWatchDir, name), dest)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anomaly is an expense that is unusually large for its category.
+type Anomaly struct {
+	Transaction Transaction `json:"transaction"`
+	Mean        Money       `json:"category_mean"`
+	StdDev      Money       `json:"category_stddev"`
+	ZScore      float64     `json:"z_score"`
+}
+
+// getAnomalies flags expenses more than `stddev` standard deviations (default
+// 2) above their category's mean. Categories with fewer than `min_samples`
+// expenses (default 5) are skipped since their statistics are just noise.
+func getAnomalies(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Type = TypeExpense
+
+	threshold, err := strconv.ParseFloat(c.DefaultQuery("stddev", "2"), 64)
+	if err != nil || threshold <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stddev must be a positive number"})
+		return
+	}
+	minSamples, err := strconv.Atoi(c.DefaultQuery("min_samples", "5"))
+	if err != nil || minSamples < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_samples must be an integer of at least 2"})
+		return
+	}
+
+	where, args := filter.where()
+	transactions, err := queryTransactions(filter.table(), where+" ORDER BY date DESC", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byCategory := map[string][]Transaction{}
+	for _, t := range transactions {
+		byCategory[t.Category] = append(byCategory[t.Category], t)
+	}
+	type stats struct{ mean, stddev float64 }
+	categoryStats := map[string]stats{}
+	for category, group := range byCategory {
+		if len(group) >= minSamples {
+			mean, stddev := amountStats(group)
+			categoryStats[category] = stats{mean, stddev}
+		}
+	}
+
+	anomalies := []Anomaly{}
+	for _, t := range transactions {
+		st, ok := categoryStats[t.Category]
+		if !ok || st.stddev == 0 {
+			continue
+		}
+		mean, stddev := st.mean, st.stddev
+		z := (t.Amount.Abs().Float() - mean) / stddev
+		if z > threshold {
+			anomalies = append(anomalies, Anomaly{
+				Transaction: t,
+				Mean:        Money(math.Round(mean * 100)),
+				StdDev:      Money(math.Round(stddev * 100)),
+				ZScore:      math.Round(z*100) / 100,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, anomalies)
+}
+
+// amountStats returns the mean and population standard deviation of the
+// absolute amounts, in currency units.
+func amountStats(transactions []Transaction) (mean, stddev float64) {
+	for _, t := range transactions {
+		mean += t.Amount.Abs().Float()
+	}
+	mean /= float64(len(transactions))
+	for _, t := range transactions {
+		d := t.Amount.Abs().Float() - mean
+		stddev += d * d
+	}
+	return mean, math.Sqrt(stddev / float64(len(transactions)))
+}
+
+type WeekdaySpending struct {
+	Weekday int    `json:"weekday"`
+	Name    string `json:"name"`
+	Total   Money  `json:"total"`
+	Count   int    `json:"count"`
+	Average Money  `json:"average"`
+}
+
+// getDayOfWeekSpending totals expenses per weekday, Sunday first, as
+// positive amounts. Average is per transaction.
+func getDayOfWeekSpending(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Type = TypeExpense
+
+	where, args := filter.where()
+	rows, err := db.Query(`
+		SELECT CAST(strftime('%w', datetime(date, ?)) AS INTEGER), SUM(ABS(amount_cents)), COUNT(*)
+		FROM `+filter.table()+`
+		`+where+`
+		GROUP BY 1
+	`, append([]any{tzModifier()}, args...)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	days := make([]WeekdaySpending, 7)
+	for i := range days {
+		days[i] = WeekdaySpending{Weekday: i, Name: time.Weekday(i).String()}
+	}
+	for rows.Next() {
+		var weekday int
+		var total Money
+		var count int
+		if err := rows.Scan(&weekday, &total, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		days[weekday].Total = total
+		days[weekday].Count = count
+		days[weekday].Average = divideMoney(total, count)
+	}
+
+	c.JSON(http.StatusOK, days)
+}
+
+type HourSpending struct {
+	Hour    int   `json:"hour"`
+	Total   Money `json:"total"`
+	Count   int   `json:"count"`
+	Average Money `json:"average"`
+}
+
+// getHourOfDaySpending totals expenses per hour of the day (0-23) in the
+// configured time zone, as positive amounts. Transactions recorded with a
+// bare date carry no time of day and are left out and counted in
+// without_time; if none have one the request fails.
+func getHourOfDaySpending(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Type = TypeExpense
+
+	conds, args := filter.conditions()
+	untimed, untimedArgs := untimedCondition()
+	var withTime, withoutTime int
+	err = db.QueryRow(`
+		SELECT COUNT(*) - COALESCE(SUM(`+untimed+`), 0), COALESCE(SUM(`+untimed+`), 0)
+		FROM `+filter.table()+`
+		`+whereClause(conds), slices.Concat(untimedArgs, untimedArgs, args)...).Scan(&withTime, &withoutTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if withTime == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "no matching transactions have a time of day; record dates with a time such as 2024-01-05T21:30:00Z to use this insight",
+		})
+		return
+	}
+
+	conds = append(conds, "NOT "+untimed)
+	args = append(args, untimedArgs...)
+	rows, err := db.Query(`
+		SELECT CAST(strftime('%H', datetime(date, ?)) AS INTEGER), SUM(ABS(amount_cents)), COUNT(*)
+		FROM `+filter.table()+`
+		`+whereClause(conds)+`
+		GROUP BY 1
+	`, append([]any{tzModifier()}, args...)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	hours := make([]HourSpending, 24)
+	for i := range hours {
+		hours[i].Hour = i
+	}
+	for rows.Next() {
+		var hour int
+		var total Money
+		var count int
+		if err := rows.Scan(&hour, &total, &count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		hours[hour].Total = total
+		hours[hour].Count = count
+		hours[hour].Average = divideMoney(total, count)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hours": hours, "without_time": withoutTime})
+}
+
+// untimedCondition is an SQL condition matching transactions recorded with
+// a bare date. Those are stored at midnight UTC or, once imported, at
+// midnight in IMPORT_TIMEZONE, so midnight is checked at UTC and at the
+// standard and daylight offsets of both that zone and TIMEZONE.
+func untimedCondition() (string, []any) {
+	conds := []string{"time(date) = '00:00:00'"}
+	var args []any
+	seen := map[int]bool{0: true}
+	year := time.Now().Year()
+	for _, loc := range []*time.Location{cfg.ImportTimezone, cfg.Timezone} {
+		for _, month := range []time.Month{time.January, time.July} {
+			_, offset := time.Date(year, month, 1, 0, 0, 0, 0, loc).Zone()
+			if !seen[offset] {
+				seen[offset] = true
+				conds = append(conds, "time(datetime(date, ?)) = '00:00:00'")
+				args = append(args, fmt.Sprintf("%+d minutes", offset/60))
+			}
+		}
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// spendingPercentiles are the percentiles getPercentiles reports.
+var spendingPercentiles = []int{25, 50, 75, 90}
+
+// getPercentiles reports the 25th, 50th, 75th and 90th percentile of
+// individual transaction amounts, as positive values, for the filtered
+// transactions; type defaults to expense. Percentiles are interpolated
+// linearly between the nearest ranks and are null when nothing matches.
+func getPercentiles(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Type == "" {
+		filter.Type = TypeExpense
+	}
+
+	where, args := filter.where()
+	rows, err := db.Query("SELECT ABS(amount_cents) FROM "+filter.table()+" "+where+" ORDER BY 1", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var amounts []Money
+	for rows.Next() {
+		var m Money
+		if err := rows.Scan(&m); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		amounts = append(amounts, m)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := gin.H{"type": filter.Type, "count": len(amounts)}
+	for _, p := range spendingPercentiles {
+		var v *Money
+		if len(amounts) > 0 {
+			m := percentile(amounts, p)
+			v = &m
+		}
+		body[fmt.Sprintf("p%d", p)] = v
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// percentile returns the p-th percentile of sorted, a non-empty ascending
+// slice.
+func percentile(sorted []Money, p int) Money {
+	rank := float64(p) / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return Money(math.Round(float64(sorted[lo]) + frac*float64(sorted[hi]-sorted[lo])))
+}
+
+// SavingsStreak counts months in a row with positive savings. Current is
+// the run ending with the current month, which is zero while this month's
+// savings aren't positive yet; Longest is the best run on record.
+type SavingsStreak struct {
+	Current     int    `json:"current"`
+	Longest     int    `json:"longest"`
+	LongestFrom string `json:"longest_from,omitempty"`
+	LongestTo   string `json:"longest_to,omitempty"`
+}
+
+// getSavingsStreak walks the monthly summaries from the first month with
+// transactions up to the current one. Months without transactions have no
+// savings and break a streak. ?cleared_only= is honored as in the summary.
+func getSavingsStreak(c *gin.Context) {
+	clearedOnly := c.Query("cleared_only") == "true"
+	current := localMonth(time.Now())
+	summaries, err := queryMonthlySummaries("", current, 0, clearedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var streak SavingsStreak
+	if len(summaries) == 0 {
+		c.JSON(http.StatusOK, streak)
+		return
+	}
+
+	breakdown, _, err := rangeBreakdown(summaries[len(summaries)-1].Month, current, clearedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	run := 0
+	for i, s := range breakdown {
+		if s.Savings <= 0 {
+			run = 0
+			continue
+		}
+		run++
+		if run > streak.Longest {
+			streak.Longest = run
+			streak.LongestFrom = breakdown[i-run+1].Month
+			streak.LongestTo = s.Month
+		}
+	}
+	streak.Current = run
+
+	c.JSON(http.StatusOK, streak)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestHourOfDaySkipsDateOnlyImports checks that dates imported without a
+// time, which land on local midnight rather than midnight UTC, are counted
+// as lacking a time of day in both winter and summer.
+func TestHourOfDaySkipsDateOnlyImports(t *testing.T) {
+	newTestDB(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+	previous, previousImport := cfg.Timezone, cfg.ImportTimezone
+	cfg.Timezone, cfg.ImportTimezone = loc, loc
+	t.Cleanup(func() { cfg.Timezone, cfg.ImportTimezone = previous, previousImport })
+
+	csv := "date,amount,category,type\n2024-01-15,-10.00,Food,expense\n2024-07-15,-20.00,Food,expense\n"
+	body, contentType := uploadBody(t, "import.csv", []byte(csv))
+	if w := serve(http.MethodPost, "/api/transactions/import", "/api/transactions/import", contentType, body, importTransactions); w.Code != http.StatusCreated {
+		t.Fatalf("import: got %d: %s", w.Code, w.Body)
+	}
+	mustInsert(t, Transaction{Date: time.Date(2024, 3, 1, 13, 30, 0, 0, loc).UTC(), Amount: -500, Category: "Food", Type: TypeExpense})
+
+	w := serve(http.MethodGet, "/api/insights/hour-of-day", "/api/insights/hour-of-day", "", "", getHourOfDaySpending)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var got struct {
+		Hours       []HourSpending `json:"hours"`
+		WithoutTime int            `json:"without_time"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.WithoutTime != 2 {
+		t.Errorf("without_time = %d, want 2", got.WithoutTime)
+	}
+	for _, h := range got.Hours {
+		want := 0
+		if h.Hour == 13 {
+			want = 1
+		}
+		if h.Count != want {
+			t.Errorf("hour %d: count %d, want %d", h.Hour, h.Count, want)
+		}
+	}
+}
+
+// TestInsightsIncludeArchived checks that include_archived brings archived
+// expenses into the insights and merchant summary rather than being ignored.
+func TestInsightsIncludeArchived(t *testing.T) {
+	newTestDB(t)
+	old := time.Now().AddDate(-5, 0, 0).Truncate(time.Hour)
+	mustInsert(t, Transaction{Date: old, Amount: -2000, Category: "Food", Description: "Grocer", Type: TypeExpense})
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		route   string
+		handler gin.HandlerFunc
+		count   func(body []byte) (int, error)
+	}{
+		{"/api/insights/day-of-week", getDayOfWeekSpending, func(body []byte) (int, error) {
+			var days []WeekdaySpending
+			n := 0
+			err := json.Unmarshal(body, &days)
+			for _, d := range days {
+				n += d.Count
+			}
+			return n, err
+		}},
+		{"/api/insights/hour-of-day", getHourOfDaySpending, func(body []byte) (int, error) {
+			var got struct {
+				Hours []HourSpending `json:"hours"`
+			}
+			n := 0
+			err := json.Unmarshal(body, &got)
+			for _, h := range got.Hours {
+				n += h.Count
+			}
+			return n, err
+		}},
+		{"/api/summary/merchants", getMerchantSummary, func(body []byte) (int, error) {
+			var merchants []MerchantSummary
+			n := 0
+			err := json.Unmarshal(body, &merchants)
+			for _, m := range merchants {
+				n += m.Count
+			}
+			return n, err
+		}},
+	} {
+		w := serve(http.MethodGet, tt.route, tt.route+"?include_archived=true", "", "", tt.handler)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.route, w.Code, w.Body)
+		}
+		n, err := tt.count(w.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.route, err)
+		}
+		if n != 1 {
+			t.Errorf("%s: counted %d transactions, want the archived one", tt.route, n)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// startJobs launches the enabled background jobs.
+func startJobs() {
+	go runEvery("budget template", time.Hour, func() error {
+		n, err := rolloverBudgetTemplate()
+		if err == nil && n > 0 {
+			log.Printf("budget template: created %d budgets for the new month", n)
+		}
+		return err
+	})
+
+	if cfg.ArchiveAfterYears > 0 {
+		go runEvery("archive", 24*time.Hour, func() error {
+			n, err := archiveOlderThan(cfg.ArchiveAfterYears)
+			if err == nil && n > 0 {
+				summaryCache.clear()
+				log.Printf("archive: moved %d transactions older than %d years", n, cfg.ArchiveAfterYears)
+			}
+			return err
+		})
+	}
+
+	if cfg.BackupInterval > 0 {
+		go runEvery("backup", cfg.BackupInterval, func() error {
+			b, err := createBackup()
+			if err == nil {
+				log.Printf("backup: wrote %s (%d bytes)", b.File, b.Size)
+			}
+			return err
+		})
+	}
+
+	if cfg.ImportWatchDir != "" {
+		go runEvery("import watch", cfg.ImportWatchInterval, scanImportDir)
+	}
+}
+
+// runEvery calls fn immediately and then once per interval, logging
+// failures rather than stopping.
+func runEvery(name string, interval time.Duration, fn func() error) {
+	for {
+		if err := fn(); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+		time.Sleep(interval)
+	}
+}
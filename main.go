@@ -2,8 +2,17 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gocarina/gocsv"
@@ -13,267 +22,1141 @@ import (
 type Transaction struct {
 	ID          int       `json:"id" csv:"id"`
 	Date        time.Time `json:"date" csv:"date"`
-	Amount      float64   `json:"amount" csv:"amount"`
+	Amount      Money     `json:"amount" csv:"amount"`
 	Category    string    `json:"category" csv:"category"`
 	Description string    `json:"description" csv:"description"`
 	Type        string    `json:"type" csv:"type"`
+	AccountID   *int      `json:"account_id" csv:"-"`
+	Account     string    `json:"account,omitempty" csv:"account"`
+	Status      string    `json:"status" csv:"status"`
+
+	// BudgetCategory, when set, counts the transaction against that
+	// category's budget instead of its own.
+	BudgetCategory string `json:"budget_category,omitempty" csv:"budget_category"`
+
+	// Flagged marks a transaction for later review.
+	Flagged bool `json:"flagged" csv:"flagged"`
+
+	// Reimbursed marks a transaction that was paid back, such as work
+	// travel. Summaries and budgets leave it out of their totals.
+	Reimbursed bool `json:"is_reimbursed" csv:"is_reimbursed"`
+
+	// Context is business or personal, defaulting to personal.
+	Context string `json:"context" csv:"context"`
+
+	Tags []string `json:"tags,omitempty" csv:"-"`
+}
+
+// transactionColumns is the column list every transaction query selects, in
+// the order scanTransaction expects.
+const transactionColumns = "id, date, amount_cents, category, description, type, account_id, (SELECT name FROM accounts WHERE accounts.id = account_id), status, COALESCE(budget_category, ''), flagged, reimbursed, context, " +
+	"(SELECT group_concat(tag, ',') FROM (SELECT tag FROM transaction_tags WHERE transaction_id = transactions.id ORDER BY tag))"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransaction(row rowScanner) (Transaction, error) {
+	var t Transaction
+	var account, tags sql.NullString
+	err := row.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.Description, &t.Type, &t.AccountID, &account, &t.Status, &t.BudgetCategory, &t.Flagged, &t.Reimbursed, &t.Context, &tags)
+	t.Account = account.String
+	if tags.Valid {
+		t.Tags = strings.Split(tags.String, ",")
+	}
+	return t, err
 }
 
 type Budget struct {
 	Category string  `json:"category" csv:"category"`
-	Amount   float64 `json:"amount" csv:"amount"`
+	Month    *string `json:"month" csv:"month"`
+	Amount   Money   `json:"amount" csv:"amount"`
+}
+
+type CategorySummary struct {
+	Category   string  `json:"category" csv:"category"`
+	Type       string  `json:"type" csv:"type"`
+	Total      Money   `json:"total" csv:"total"`
+	Count      int     `json:"count" csv:"count"`
+	Percentage float64 `json:"percentage" csv:"percentage"`
+	Color      string  `json:"color,omitempty" csv:"-"`
+	Icon       string  `json:"icon,omitempty" csv:"-"`
 }
 
 type MonthlySummary struct {
-	Month        string  `json:"month"`
-	TotalIncome  float64 `json:"total_income"`
-	TotalExpense float64 `json:"total_expense"`
-	Savings      float64 `json:"savings"`
+	Month        string `json:"month" csv:"month"`
+	TotalIncome  Money  `json:"total_income" csv:"total_income"`
+	TotalExpense Money  `json:"total_expense" csv:"total_expense"`
+	Savings      Money  `json:"savings" csv:"savings"`
 }
 
 var db *sql.DB
 
+// dbPath is the SQLite database file.
+const dbPath = "./finance.db"
+
+const (
+	maxPageSize = 500
+
+	exportBatchSize = 500
+)
+
 func main() {
+	loadConfig()
+
 	var err error
-	db, err = sql.Open("sqlite3", "./finance.db")
+	db, err = sql.Open(driverName(), dbPath)
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
 
-	createTables()
+	if err := migrate(db); err != nil {
+		panic(err)
+	}
+	storage = newStorage()
+	startJobs()
 
 	r := gin.Default()
 
+	// Filled in once every route is registered, before the server starts.
+	var allowMethods string
+	maxAge := strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
+
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", allowMethods)
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		if c.Request.Method == "OPTIONS" {
+			c.Writer.Header().Set("Access-Control-Max-Age", maxAge)
 			c.AbortWithStatus(204)
 			return
 		}
 		c.Next()
 	})
+	r.Use(envelopeMiddleware())
+	r.Use(bodyLimitMiddleware())
+	r.Use(summaryCache.middleware())
 
+	r.GET("/api/health", getHealth)
+	r.GET("/api/ready", getReady)
+	r.GET("/api/config", getConfig)
 	r.GET("/api/transactions", getTransactions)
+	r.GET("/api/transactions/recent", getRecentTransactions)
+	r.GET("/api/transactions/count", countTransactions)
+	r.GET("/api/transactions/largest", getLargestTransactions)
+	r.GET("/api/transactions/grouped", getGroupedTransactions)
+	r.POST("/api/transactions/:id/flag", toggleFlag)
+	r.POST("/api/transactions/:id/reimbursed", toggleReimbursed)
+	r.GET("/api/transactions/:id/attachments", getAttachments)
+	r.POST("/api/transactions/:id/attachments", addAttachment)
+	r.GET("/api/attachments/:id", downloadAttachment)
+	r.DELETE("/api/attachments/:id", deleteAttachment)
+	r.GET("/api/transactions/duplicates", getDuplicates)
+	r.DELETE("/api/transactions/duplicates", deleteDuplicates)
+	r.GET("/api/transactions/:id", getTransaction)
+	r.GET("/api/transactions/:id/suggest-category", suggestCategory)
+	r.POST("/api/transactions/tag-by-filter", tagByFilter)
+	r.POST("/api/transactions/shift-dates", shiftDates)
 	r.POST("/api/transactions", addTransaction)
+	r.DELETE("/api/transactions", clearTransactions)
 	r.DELETE("/api/transactions/:id", deleteTransaction)
 	r.POST("/api/transactions/import", importTransactions)
-	r.GET("/api/transactions/export", exportTransactions)
+	r.POST("/api/transactions/import/stream", importTransactionsStream)
+	r.POST("/api/transactions/import/url", importTransactionsFromURL)
+	r.GET("/api/transactions/export", negotiateExport)
+	r.GET("/api/transactions/export/qif", exportQIF)
+	r.GET("/api/transactions/export/xlsx", exportXLSX)
+	r.GET("/api/accounts", getAccounts)
+	r.POST("/api/accounts", addAccount)
+	r.GET("/api/categories/meta", getCategoryMeta)
+	r.GET("/api/categories/stale", getStaleCategories)
+	r.POST("/api/categories/merge", mergeCategories)
+	r.PUT("/api/categories/:category/meta", setCategoryMeta)
+	r.DELETE("/api/categories/:category/meta", deleteCategoryMeta)
+	r.GET("/api/budgets", getBudgets)
+	r.POST("/api/budgets", addBudget)
+	r.GET("/api/budgets/status", getBudgetStatus)
+	r.GET("/api/budgets/summary", getBudgetSummary)
+	r.GET("/api/budgets/template", getBudgetTemplate)
+	r.PUT("/api/budgets/template", setBudgetTemplate)
+	r.POST("/api/budgets/template/apply", applyBudgetTemplate)
+	r.GET("/api/budgets/recommendations", getBudgetRecommendations)
+	r.POST("/api/budgets/recommendations/apply", applyBudgetRecommendations)
+	r.POST("/api/budgets/simulate", simulateBudgets)
+	r.PUT("/api/budgets/:category", updateBudget)
+	r.GET("/api/budgets/:category/burndown", getBudgetBurndown)
+	r.DELETE("/api/budgets/:category", deleteBudget)
+	r.GET("/api/insights/anomalies", getAnomalies)
+	r.GET("/api/insights/day-of-week", getDayOfWeekSpending)
+	r.GET("/api/insights/hour-of-day", getHourOfDaySpending)
+	r.GET("/api/insights/percentiles", getPercentiles)
+	r.GET("/api/insights/savings-streak", getSavingsStreak)
+	r.GET("/api/export/all", exportAll)
+	r.POST("/api/import/backup", importBackup)
+	r.POST("/api/admin/archive", archiveTransactions)
+	r.POST("/api/admin/normalize-signs", normalizeSigns)
+	r.POST("/api/admin/backup", backupDatabase)
+	r.POST("/api/admin/restore", restoreDatabase)
+	r.POST("/api/reconcile", reconcile)
+	r.GET("/api/closed-periods", getClosedPeriods)
+	r.PUT("/api/closed-periods/:month", closePeriod)
+	r.DELETE("/api/closed-periods/:month", reopenPeriod)
+	r.GET("/api/allocation-rules", getAllocationRules)
+	r.PUT("/api/allocation-rules", setAllocationRules)
+	r.POST("/api/income/:id/allocate", allocateIncome)
+	r.GET("/api/recurring", getRecurring)
+	r.POST("/api/recurring", addRecurring)
+	r.DELETE("/api/recurring/:id", deleteRecurring)
+	r.GET("/api/forecast", getForecast)
+	r.GET("/api/goals", getGoals)
+	r.POST("/api/goals", addGoal)
+	r.PUT("/api/goals/:id", updateGoal)
+	r.DELETE("/api/goals/:id", deleteGoal)
+	r.GET("/api/goals/:id/status", getGoalStatus)
+	r.GET("/api/assets", assets.list)
+	r.POST("/api/assets", assets.add)
+	r.PUT("/api/assets/:id", assets.update)
+	r.DELETE("/api/assets/:id", assets.delete)
+	r.GET("/api/liabilities", liabilities.list)
+	r.POST("/api/liabilities", liabilities.add)
+	r.PUT("/api/liabilities/:id", liabilities.update)
+	r.DELETE("/api/liabilities/:id", liabilities.delete)
+	r.GET("/api/networth", getNetWorth)
+	r.POST("/api/reports/monthly/send", sendMonthlyReport)
+	r.GET("/api/reports/month-end", getMonthEndReport)
+	r.GET("/api/search", search)
 	r.GET("/api/summary/monthly", getMonthlySummary)
+	r.GET("/api/summary/range", getRangeSummary)
+	r.GET("/api/summary/fiscal-year", getFiscalYearSummary)
+	r.GET("/api/summary/savings-rate-trend", getSavingsRateTrend)
+	r.GET("/api/summary/ratio", getExpenseRatio)
+	r.GET("/api/summary/fixed-vs-discretionary", getFixedVsDiscretionary)
+	r.GET("/api/summary/context", getContextSummary)
+	r.GET("/api/summary/month-compare", getMonthCompare)
+	r.GET("/api/summary/available-months", getAvailableMonths)
 	r.GET("/api/summary/categories", getCategorySummary)
+	r.GET("/api/summary/categories/export", exportCategorySummary)
+	r.GET("/api/summary/averages", getCategoryAverages)
+	r.GET("/api/summary/merchants", getMerchantSummary)
+	r.GET("/api/merchant-rules", getMerchantRules)
+	r.POST("/api/merchant-rules", addMerchantRule)
+	r.DELETE("/api/merchant-rules/:id", deleteMerchantRule)
+
+	allowMethods = routeMethods(r.Routes())
 
-	r.Run(":8080")
+	srv := &http.Server{
+		Addr:         ":8080",
+		Handler:      r,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		panic(err)
+	}
 }
 
-func createTables() {
+// routeMethods lists every HTTP method used by a registered route, plus
+// OPTIONS for preflights, in the form Access-Control-Allow-Methods expects.
+func routeMethods(routes gin.RoutesInfo) string {
+	methods := []string{http.MethodOptions}
+	for _, route := range routes {
+		if !slices.Contains(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
 
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS transactions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			date DATE NOT NULL,
-			amount REAL NOT NULL,
-			category TEXT NOT NULL,
-			description TEXT,
-			type TEXT NOT NULL
-		)
-	`)
+// getTransactions lists transactions newest first. Passing limit or
+// after_id switches to cursor pagination: the response becomes
+// {transactions, next_cursor}, and next_cursor is fed back as after_id to
+// fetch the following page.
+func getTransactions(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
 	if err != nil {
-		panic(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+	case "ndjson":
+		streamTransactionsNDJSON(c, filter)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or ndjson"})
+		return
 	}
+
+	if c.Query("limit") == "" && c.Query("after_id") == "" {
+		where, args := filter.where()
+		transactions, err := queryTransactions(filter.table(), where+" ORDER BY date DESC", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, transactions)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(cfg.DefaultPageSize)))
+	if err != nil || limit < 1 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	conds, args := filter.conditions()
+	if v := c.Query("after_id"); v != "" {
+		afterID, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "after_id must be an integer"})
+			return
+		}
+		var afterDate string
+		err = db.QueryRow("SELECT CAST(date AS TEXT) FROM "+filter.table()+" WHERE id = ?", afterID).Scan(&afterDate)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "after_id does not refer to an existing transaction"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Keyset on (date, id) so the page order matches the unpaginated
+		// listing and ties on date are broken consistently.
+		conds = append(conds, "(date < ? OR (date = ? AND id < ?))")
+		args = append(args, afterDate, afterDate, afterID)
+	}
+
+	args = append(args, limit+1)
+	transactions, err := queryTransactions(filter.table(), whereClause(conds)+" ORDER BY date DESC, id DESC LIMIT ?", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var nextCursor *int
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		nextCursor = &transactions[limit-1].ID
+	}
+	if transactions == nil {
+		transactions = []Transaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"next_cursor":  nextCursor,
+	})
 }
 
-func getTransactions(c *gin.Context) {
-	rows, err := db.Query("SELECT id, date, amount, category, description, type FROM transactions ORDER BY date DESC")
+// streamTransactionsNDJSON writes the filtered transactions, newest first,
+// as one JSON object per line while they are read, without buffering the
+// result. Pagination parameters are ignored.
+func streamTransactionsNDJSON(c *gin.Context, filter transactionFilter) {
+	where, args := filter.where()
+	rows, err := db.Query("SELECT "+transactionColumns+" FROM "+filter.table()+" "+where+" ORDER BY date DESC, id DESC", args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	var transactions []Transaction
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	n := 0
 	for rows.Next() {
-		var t Transaction
-		err := rows.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.Description, &t.Type)
+		t, err := scanTransaction(rows)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
-		transactions = append(transactions, t)
+		if err := enc.Encode(t); err != nil {
+			c.Error(err)
+			return
+		}
+		if n++; n%exportBatchSize == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+	}
+}
+
+// maxRecentTransactions caps the recent-activity endpoint.
+const maxRecentTransactions = 50
+
+// getRecentTransactions returns the latest ?limit= transactions (default 5)
+// for the dashboard's recent activity list.
+func getRecentTransactions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	limit = min(limit, maxRecentTransactions)
+
+	transactions, err := queryTransactions("transactions", "ORDER BY date DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if transactions == nil {
+		transactions = []Transaction{}
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// getLargestTransactions lists the individual transactions with the
+// biggest absolute amounts, honoring the list filters such as type and the
+// date range. limit defaults to 10 and is capped at maxPageSize.
+func getLargestTransactions(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	where, args := filter.where()
+	transactions, err := queryTransactions(filter.table(), where+" ORDER BY ABS(amount_cents) DESC, date DESC, id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if transactions == nil {
+		transactions = []Transaction{}
 	}
 
 	c.JSON(http.StatusOK, transactions)
 }
 
+// getGroupedTransactions returns the filtered transactions as a map from
+// category to its transactions, newest first. ?by= must be category, the
+// only grouping so far. ?per_group= keeps just that many of each.
+func getGroupedTransactions(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if by := c.DefaultQuery("by", "category"); by != "category" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be category"})
+		return
+	}
+	perGroup := 0
+	if v := c.Query("per_group"); v != "" {
+		perGroup, err = strconv.Atoi(v)
+		if err != nil || perGroup < 1 || perGroup > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("per_group must be between 1 and %d", maxPageSize)})
+			return
+		}
+	}
+
+	where, args := filter.where()
+	transactions, err := queryTransactions(filter.table(), where+" ORDER BY date DESC, id DESC", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	groups := map[string][]Transaction{}
+	for _, t := range transactions {
+		if perGroup == 0 || len(groups[t.Category]) < perGroup {
+			groups[t.Category] = append(groups[t.Category], t)
+		}
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// countTransactions returns how many transactions match the list filters,
+// for badges that don't need the rows themselves.
+func countTransactions(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	where, args := filter.where()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+filter.table()+" "+where, args...).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// queryTransactions selects transactions from table (normally
+// "transactions", see transactionFilter.table) with the given trailing SQL,
+// such as a WHERE and ORDER BY clause.
+func queryTransactions(table, tail string, args ...any) ([]Transaction, error) {
+	return queryTransactionsIn(db, table, tail, args...)
+}
+
+// queryTransactionsIn is queryTransactions reading through q, for callers
+// inside a database transaction.
+func queryTransactionsIn(q queryer, table, tail string, args ...any) ([]Transaction, error) {
+	rows, err := q.Query("SELECT "+transactionColumns+" FROM "+table+" "+tail, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
 func addTransaction(c *gin.Context) {
 	var t Transaction
 	if err := c.ShouldBindJSON(&t); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if t.Type == "" {
+		t.Type = cfg.DefaultTransactionType
+	}
+
+	if err := validateTransaction(&t); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": err})
+		return
+	}
+
+	if err := checkPeriodsOpen(&t); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
 
-	if t.Type == "expense" && t.Amount > 0 {
+	if t.Type == TypeExpense && t.Amount > 0 || t.Type == TypeRefund && t.Amount < 0 {
 		t.Amount = -t.Amount
 	}
 
-	result, err := db.Exec(
-		"INSERT INTO transactions (date, amount, category, description, type) VALUES (?, ?, ?, ?, ?)",
-		t.Date, t.Amount, t.Category, t.Description, t.Type,
-	)
+	warnings, err := transactionWarnings(&t)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = withTx(func(tx *sql.Tx) error {
+		if t.AccountID != nil {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM accounts WHERE id = ?)", *t.AccountID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return ValidationError{"account_id": fmt.Sprintf("account %d does not exist", *t.AccountID)}
+			}
+		}
+		id, err := insertTransaction(tx, &t)
+		t.ID = int(id)
+		return err
+	})
+	var verr ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	t.ID = int(id)
-	c.JSON(http.StatusCreated, t)
+	c.Header("Location", fmt.Sprintf("/api/transactions/%d", t.ID))
+	c.JSON(http.StatusCreated, struct {
+		Transaction
+		Warnings []string `json:"warnings,omitempty"`
+	}{t, warnings})
 }
 
-func deleteTransaction(c *gin.Context) {
+// unusualAmountFactor is how many times the category average an amount must
+// be before addTransaction warns about it.
+const unusualAmountFactor = 10
+
+// transactionWarnings flags likely typos in a transaction that is valid but
+// unusual. Warnings never block the insert.
+func transactionWarnings(t *Transaction) ([]string, error) {
+	var warnings []string
+
+	var count int
+	var total Money
+	err := db.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(ABS(amount_cents)), 0) FROM transactions WHERE category = ? AND type = ?",
+		t.Category, t.Type,
+	).Scan(&count, &total)
+	if err != nil {
+		return nil, err
+	}
+	// A handful of earlier transactions is needed before the average
+	// means anything.
+	if avg := divideMoney(total, count); count >= 3 && avg > 0 {
+		if ratio := int64(t.Amount.Abs() / avg); ratio >= unusualAmountFactor {
+			warnings = append(warnings, fmt.Sprintf("amount is %dx the %s average of %s", ratio, t.Category, avg))
+		}
+	}
+
+	return warnings, nil
+}
+
+// validateTransaction checks a parsed transaction field by field. The
+// returned error, if any, is a ValidationError.
+func validateTransaction(t *Transaction) error {
+	errs := ValidationError{}
+	if t.Date.IsZero() {
+		errs["date"] = "is required"
+	} else if limit := time.Now().AddDate(0, 0, cfg.MaxFutureDays); t.Date.After(limit) {
+		errs["date"] = fmt.Sprintf("%s is more than %d days in the future", t.Date.Format("2006-01-02"), cfg.MaxFutureDays)
+	}
+	if t.Amount == 0 {
+		errs["amount"] = "must not be zero"
+	}
+	t.Category = strings.TrimSpace(t.Category)
+	if t.Category == "" {
+		t.Category = cfg.UncategorizedLabel
+	}
+	if t.Category == "" {
+		errs["category"] = "is required"
+	}
+	if !isTransactionType(t.Type) {
+		errs["type"] = transactionTypeError()
+	}
+	if limit := cfg.MaxDescriptionLength; limit > 0 && utf8.RuneCountInString(t.Description) > limit {
+		if cfg.DescriptionOverflow == "reject" {
+			errs["description"] = fmt.Sprintf("must be at most %d characters", limit)
+		} else {
+			t.Description = string([]rune(t.Description)[:limit-1]) + "…"
+		}
+	}
+	t.BudgetCategory = strings.TrimSpace(t.BudgetCategory)
+	if t.Status == "" {
+		t.Status = StatusCleared
+	} else if !isTransactionStatus(t.Status) {
+		errs["status"] = fmt.Sprintf("must be %s or %s", StatusPending, StatusCleared)
+	}
+	t.Context = strings.ToLower(strings.TrimSpace(t.Context))
+	if t.Context == "" {
+		t.Context = ContextPersonal
+	} else if !isTransactionContext(t.Context) {
+		errs["context"] = fmt.Sprintf("must be %s or %s", ContextBusiness, ContextPersonal)
+	}
+	return errs.err()
+}
+
+func getTransaction(c *gin.Context) {
+	t, err := scanTransaction(db.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE id = ?", c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// toggleFlag flips whether a transaction is flagged for review and returns
+// the updated transaction.
+func toggleFlag(c *gin.Context) {
+	result, err := db.Exec("UPDATE transactions SET flagged = NOT flagged WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	getTransaction(c)
+}
+
+// toggleReimbursed flips whether a transaction was reimbursed and returns
+// the updated transaction. Since reimbursed expenses drop out of the
+// totals, transactions in closed periods can't be toggled.
+func toggleReimbursed(c *gin.Context) {
 	id := c.Param("id")
-	_, err := db.Exec("DELETE FROM transactions WHERE id = ?", id)
+	var t Transaction
+	err := db.QueryRow("SELECT date FROM transactions WHERE id = ?", id).Scan(&t.Date)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	if err == nil {
+		err = checkPeriodsOpen(&t)
+	}
 	if err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	if _, err := db.Exec("UPDATE transactions SET reimbursed = NOT reimbursed WHERE id = ?", id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.Status(http.StatusNoContent)
+	getTransaction(c)
 }
 
-func importTransactions(c *gin.Context) {
-	file, _, err := c.Request.FormFile("file")
+func deleteTransaction(c *gin.Context) {
+	id := c.Param("id")
+	var t Transaction
+	err := db.QueryRow("SELECT date FROM transactions WHERE id = ?", id).Scan(&t.Date)
+	if err == sql.ErrNoRows {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err == nil {
+		err = checkPeriodsOpen(&t)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondPeriodError(c, err)
 		return
 	}
-	defer file.Close()
 
-	var transactions []*Transaction
-	if err := gocsv.Unmarshal(file, &transactions); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	err = withTx(func(tx *sql.Tx) error {
+		for _, query := range []string{
+			"DELETE FROM transactions WHERE id = ?",
+			"DELETE FROM allocations WHERE income_id = ?",
+			"DELETE FROM transaction_tags WHERE transaction_id = ?",
+		} {
+			if _, err := tx.Exec(query, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = removeAttachments(c.Request.Context(), "transaction_id = ?", id)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// clearTransactions deletes every transaction. It requires ?confirm=true so
+// a stray request can't wipe the dataset; ?reset_ids=true also restarts IDs
+// at 1.
+func clearTransactions(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pass confirm=true to delete all transactions"})
+		return
+	}
+	if err := checkNoClosedTransactions(); err != nil {
+		respondPeriodError(c, err)
 		return
 	}
 
-	tx, err := db.Begin()
+	var keys []string
+	var deleted int64
+	err := withTx(func(tx *sql.Tx) error {
+		// Archived transactions keep their tags, allocations and
+		// attachments, so only those of the rows being cleared go, while
+		// they still exist.
+		for _, query := range []string{
+			"DELETE FROM transaction_tags WHERE transaction_id IN (SELECT id FROM transactions)",
+			"DELETE FROM allocations WHERE income_id IN (SELECT id FROM transactions)",
+		} {
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		var err error
+		keys, err = deleteAttachmentRows(tx, "transaction_id IN (SELECT id FROM transactions)")
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM transactions")
+		if err != nil {
+			return err
+		}
+		deleted, _ = result.RowsAffected()
+		if c.Query("reset_ids") == "true" {
+			return resetTransactionIDs(tx)
+		}
+		return nil
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	deleteAttachmentFiles(c.Request.Context(), keys)
 
-	for _, t := range transactions {
-		_, err := tx.Exec(
-			"INSERT INTO transactions (date, amount, category, description, type) VALUES (?, ?, ?, ?, ?)",
-			t.Date, t.Amount, t.Category, t.Description, t.Type,
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// resetTransactionIDs restarts the AUTOINCREMENT counter just past the
+// highest ID still in use, counting archived transactions, whose IDs new
+// rows must not reuse or archiving them would fail. sqlite_sequence is
+// SQLite's own bookkeeping table; other databases reset sequences
+// differently, so this must stay behind the SQLite driver.
+func resetTransactionIDs(tx *sql.Tx) error {
+	if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = 'transactions'"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO sqlite_sequence (name, seq)
+		SELECT 'transactions', MAX(id) FROM (
+			SELECT id FROM transactions UNION ALL SELECT id FROM archived_transactions
 		)
+		HAVING MAX(id) IS NOT NULL
+	`)
+	return err
+}
+
+// exportFormats are the Accept types exportTransactions can answer, in
+// order of preference; CSV is the default.
+var exportFormats = []string{"text/csv", gin.MIMEJSON, "application/x-ndjson", "application/qif", mimeXLSX}
+
+// negotiateExport answers GET /api/transactions/export in the format the
+// Accept header asks for: CSV, a JSON array, NDJSON, QIF or an Excel
+// workbook. A request without an Accept header, or accepting anything, gets
+// CSV; one accepting none of these gets 406 listing them.
+func negotiateExport(c *gin.Context) {
+	switch c.NegotiateFormat(exportFormats...) {
+	case "text/csv":
+		exportTransactions(c)
+	case gin.MIMEJSON:
+		transactions, err := queryTransactions("transactions", "ORDER BY date DESC, id DESC")
 		if err != nil {
-			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if transactions == nil {
+			transactions = []Transaction{}
+		}
+		c.JSON(http.StatusOK, transactions)
+	case "application/x-ndjson":
+		streamTransactionsNDJSON(c, transactionFilter{})
+	case "application/qif":
+		exportQIF(c)
+	case mimeXLSX:
+		exportXLSX(c)
+	default:
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "export is available as " + strings.Join(exportFormats, ", ")})
 	}
-
-	tx.Commit()
-	c.Status(http.StatusCreated)
 }
 
+// exportTransactions streams the CSV in batches of exportBatchSize rows,
+// flushing after each one, so memory stays flat however large the table is.
+// Once streaming has started the status can no longer change, so a failure
+// part-way through ends the download early and is recorded on the context.
+//
+// Amounts are written with ?decimals places (0-4, default 2) and the ?sign
+// convention: minus (default), plus, or none for absolute values.
 func exportTransactions(c *gin.Context) {
-	rows, err := db.Query("SELECT id, date, amount, category, description, type FROM transactions")
+	format, err := parseMoneyFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query("SELECT " + transactionColumns + " FROM transactions")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	var transactions []Transaction
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment;filename=transactions.csv")
+	c.Status(http.StatusOK)
+
+	w := &amountWriter{CSVWriter: gocsv.DefaultCSVWriter(c.Writer), format: format, column: -1}
+	if err := gocsv.MarshalCSV([]Transaction{}, w); err != nil {
+		c.Error(err)
+		return
+	}
+
+	batch := make([]Transaction, 0, exportBatchSize)
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := gocsv.MarshalCSVWithoutHeaders(batch, w); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	}
 	for rows.Next() {
-		var t Transaction
-		err := rows.Scan(&t.ID, &t.Date, &t.Amount, &t.Category, &t.Description, &t.Type)
+		t, err := scanTransaction(rows)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
-		transactions = append(transactions, t)
+		batch = append(batch, t)
+		if len(batch) == exportBatchSize {
+			if err := flush(); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := flush(); err != nil {
+		c.Error(err)
+	}
+}
+
+func parseMoneyFormat(c *gin.Context) (moneyFormat, error) {
+	f := defaultMoneyFormat
+	if v := c.Query("decimals"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxMoneyDecimals {
+			return f, fmt.Errorf("decimals must be between 0 and %d", maxMoneyDecimals)
+		}
+		f.Decimals = n
+	}
+	if v := c.Query("sign"); v != "" {
+		if v != signMinus && v != signPlus && v != signNone {
+			return f, fmt.Errorf("sign must be %s, %s or %s", signMinus, signPlus, signNone)
+		}
+		f.Sign = v
 	}
+	return f, nil
+}
+
+// amountWriter rewrites the amount column of each record gocsv writes
+// through it in its own format. The first record is the header, which
+// locates the column.
+type amountWriter struct {
+	gocsv.CSVWriter
+	format moneyFormat
+	column int
+}
 
-	csvContent, err := gocsv.MarshalString(transactions)
+func (w *amountWriter) Write(row []string) error {
+	if w.column < 0 {
+		w.column = slices.Index(row, "amount")
+		return w.CSVWriter.Write(row)
+	}
+	m, err := parseMoney(row[w.column])
+	if err != nil {
+		return err
+	}
+	row[w.column] = w.format.format(m)
+	return w.CSVWriter.Write(row)
+}
+
+func getMonthlySummary(c *gin.Context) {
+	summaries, err := queryMonthlySummaries("", "", 12, c.Query("cleared_only") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Header("Content-Type", "text/csv")
-	c.Header("Content-Disposition", "attachment;filename=transactions.csv")
-	c.String(http.StatusOK, csvContent)
+	c.JSON(http.StatusOK, summaries)
 }
 
-func getMonthlySummary(c *gin.Context) {
-	rows, err := db.Query(`
+// queryMonthlySummaries totals income and expense per month, newest first.
+// from and to are inclusive YYYY-MM bounds and may be empty; a limit of 0
+// means no limit. clearedOnly leaves out pending transactions. Months
+// without transactions are omitted.
+//
+// Reimbursed transactions are left out of both totals. A reimbursed expense
+// therefore no longer lowers savings; if the repayment was recorded as
+// income, mark it reimbursed too so the pair nets to zero rather than
+// raising savings.
+func queryMonthlySummaries(from, to string, limit int, clearedOnly bool) ([]MonthlySummary, error) {
+	conds := []string{"NOT reimbursed"}
+	tz := tzModifier()
+	args := []any{tz, TypeIncome, TypeExpense, TypeRefund}
+	if from != "" {
+		conds = append(conds, "strftime('%Y-%m', datetime(date, ?)) >= ?")
+		args = append(args, tz, from)
+	}
+	if to != "" {
+		conds = append(conds, "strftime('%Y-%m', datetime(date, ?)) <= ?")
+		args = append(args, tz, to)
+	}
+	if clearedOnly {
+		conds = append(conds, "status = ?")
+		args = append(args, StatusCleared)
+	}
+	where := "WHERE " + strings.Join(conds, " AND ")
+	query := `
         SELECT 
-            strftime('%Y-%m', date) as month,
-            ROUND(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 2) as income,
-            ROUND(SUM(CASE WHEN type = 'expense' THEN ABS(amount) ELSE 0 END), 2) as expense
+            strftime('%Y-%m', datetime(date, ?)) as month,
+            SUM(CASE WHEN type = ? THEN amount_cents ELSE 0 END) as income,
+            SUM(CASE WHEN type = ? THEN ABS(amount_cents) WHEN type = ? THEN -ABS(amount_cents) ELSE 0 END) as expense
         FROM transactions
-        GROUP BY strftime('%Y-%m', date)
+        ` + where + `
+        GROUP BY month
         ORDER BY month DESC
-        LIMIT 12
-    `)
+    `
+	if limit > 0 {
+		query += fmt.Sprintf("LIMIT %d", limit)
+	}
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
 	var summaries []MonthlySummary
 	for rows.Next() {
 		var s MonthlySummary
-		var income, expense float64
+		var income, expense Money
 		err := rows.Scan(&s.Month, &income, &expense)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
 		s.TotalIncome = income
 		s.TotalExpense = expense
 		s.Savings = income - expense
 		summaries = append(summaries, s)
 	}
+	return summaries, rows.Err()
+}
+
+// getCategorySummary returns every category by default. ?sort=total orders
+// them by absolute total, largest first, and ?limit= and ?offset= page
+// through them, with the full count in X-Total-Count for "show more".
+// Percentages are always of the whole, not of the page.
+func getCategorySummary(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, offset := 0, 0
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &limit}, {"offset", &offset}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be a non-negative integer"})
+				return
+			}
+			*p.dst = n
+		}
+	}
+	if v := c.Query("sort"); v != "" && v != "total" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be total"})
+		return
+	}
+
+	summaries, err := queryCategorySummary(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("sort") == "total" {
+		sort.SliceStable(summaries, func(i, j int) bool {
+			return summaries[i].Total.Abs() > summaries[j].Total.Abs()
+		})
+	}
+	c.Header("X-Total-Count", strconv.Itoa(len(summaries)))
+	summaries = summaries[min(offset, len(summaries)):]
+	if limit > 0 {
+		summaries = summaries[:min(limit, len(summaries))]
+	}
+	if summaries == nil {
+		summaries = []CategorySummary{}
+	}
 
 	c.JSON(http.StatusOK, summaries)
 }
 
-func getCategorySummary(c *gin.Context) {
+func exportCategorySummary(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries, err := queryCategorySummary(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	csvContent, err := gocsv.MarshalString(summaries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment;filename=category-summary.csv")
+	c.String(http.StatusOK, csvContent)
+}
+
+// queryCategorySummary totals transactions per category and type. Percentage
+// is each category's share of the total for its type. Refunds are reported
+// as expense, netted against their category's spending. Reimbursed
+// transactions are left out unless the filter asks for them.
+func queryCategorySummary(filter transactionFilter) ([]CategorySummary, error) {
+	if filter.Reimbursed == nil {
+		reimbursed := false
+		filter.Reimbursed = &reimbursed
+	}
+	where, args := filter.where()
 	rows, err := db.Query(`
 		SELECT 
 			category,
-			SUM(amount) as total,
-			type
-		FROM transactions
-		GROUP BY category, type
+			CASE WHEN type = ? THEN ? ELSE type END as type,
+			SUM(CASE WHEN type = ? THEN ABS(amount_cents) ELSE amount_cents END) as total,
+			COUNT(*),
+			COALESCE((SELECT color FROM category_meta m WHERE m.category = transactions.category), ''),
+			COALESCE((SELECT icon FROM category_meta m WHERE m.category = transactions.category), '')
+		FROM `+filter.table()+`
+		`+where+`
+		GROUP BY 1, 2
 		ORDER BY type, total DESC
-	`)
+	`, append([]any{TypeRefund, TypeExpense, TypeRefund}, args...)...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
-	type CategorySummary struct {
-		Category string  `json:"category"`
-		Total    float64 `json:"total"`
-		Type     string  `json:"type"`
-	}
-
 	var summaries []CategorySummary
+	typeTotals := map[string]Money{}
 	for rows.Next() {
 		var s CategorySummary
-		err := rows.Scan(&s.Category, &s.Total, &s.Type)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		if err := rows.Scan(&s.Category, &s.Type, &s.Total, &s.Count, &s.Color, &s.Icon); err != nil {
+			return nil, err
 		}
+		typeTotals[s.Type] += s.Total
 		summaries = append(summaries, s)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	c.JSON(http.StatusOK, summaries)
+	for i := range summaries {
+		if total := typeTotals[summaries[i].Type]; total != 0 {
+			pct := float64(summaries[i].Total) / float64(total) * 100
+			summaries[i].Percentage = math.Round(pct*100) / 100
+		}
+	}
+	return summaries, nil
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	loadConfig()
+	os.Exit(m.Run())
+}
+
+// newTestDB points db at a fresh, migrated database, and storage at an
+// empty directory, for the duration of the test.
+func newTestDB(t *testing.T) {
+	t.Helper()
+	d, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(d); err != nil {
+		t.Fatal(err)
+	}
+	previous, previousStorage := db, storage
+	db, storage = d, localStorage{dir: t.TempDir()}
+	t.Cleanup(func() {
+		db, storage = previous, previousStorage
+		d.Close()
+	})
+}
+
+// serve runs one request through handler and returns the recorded
+// response. path is matched against route so handlers can read params.
+func serve(method, route, path, contentType, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, route, handler)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func mustInsert(t *testing.T, tr Transaction) {
+	t.Helper()
+	if err := validateTransaction(&tr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := insertTransaction(db, &tr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// uploadBody encodes content as the "file" field of a multipart form and
+// returns the body and its Content-Type.
+func uploadBody(t *testing.T, filename string, content []byte) (string, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body.String(), mw.FormDataContentType()
+}
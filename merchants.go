@@ -0,0 +1,293 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MerchantRule maps descriptions matching Pattern, a case-insensitive
+// regular expression, to a canonical merchant name.
+type MerchantRule struct {
+	ID       int    `json:"id"`
+	Pattern  string `json:"pattern"`
+	Merchant string `json:"merchant"`
+}
+
+type MerchantSummary struct {
+	Merchant string `json:"merchant"`
+	Total    Money  `json:"total"`
+	Count    int    `json:"count"`
+}
+
+// merchantNormalizer turns raw bank descriptions into merchant names. Rules
+// are tried in ID order; descriptions no rule matches fall back to
+// stripping reference codes.
+type merchantNormalizer struct {
+	rules []compiledMerchantRule
+}
+
+type compiledMerchantRule struct {
+	re       *regexp.Regexp
+	merchant string
+}
+
+var (
+	merchantCodeSuffix = regexp.MustCompile(`[*#].*$`)
+	merchantSpaces     = regexp.MustCompile(`\s+`)
+)
+
+func loadMerchantNormalizer() (*merchantNormalizer, error) {
+	rules, err := queryMerchantRules()
+	if err != nil {
+		return nil, err
+	}
+	n := &merchantNormalizer{}
+	for _, r := range rules {
+		re, err := compileMerchantPattern(r.Pattern)
+		if err != nil {
+			// Patterns are validated on insert, so this only happens if
+			// the table was edited by hand; skip the broken rule.
+			continue
+		}
+		n.rules = append(n.rules, compiledMerchantRule{re, r.Merchant})
+	}
+	return n, nil
+}
+
+// unknownMerchant is what normalize returns for a description with no
+// usable words, such as a bare reference number.
+const unknownMerchant = "UNKNOWN"
+
+func (n *merchantNormalizer) normalize(description string) string {
+	for _, r := range n.rules {
+		if r.re.MatchString(description) {
+			return r.merchant
+		}
+	}
+
+	s := strings.ToUpper(strings.TrimSpace(description))
+	s = merchantCodeSuffix.ReplaceAllString(s, "")
+	var words []string
+	for _, w := range merchantSpaces.Split(s, -1) {
+		if w != "" && !strings.ContainsAny(w, "0123456789") {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return unknownMerchant
+	}
+	return strings.Join(words, " ")
+}
+
+func compileMerchantPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+func queryMerchantRules() ([]MerchantRule, error) {
+	rows, err := db.Query("SELECT id, pattern, merchant FROM merchant_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []MerchantRule{}
+	for rows.Next() {
+		var r MerchantRule
+		if err := rows.Scan(&r.ID, &r.Pattern, &r.Merchant); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func getMerchantRules(c *gin.Context) {
+	rules, err := queryMerchantRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func addMerchantRule(c *gin.Context) {
+	var r MerchantRule
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateMerchantRule(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO merchant_rules (pattern, merchant) VALUES (?, ?)", r.Pattern, r.Merchant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	r.ID = int(id)
+	c.JSON(http.StatusCreated, r)
+}
+
+func deleteMerchantRule(c *gin.Context) {
+	_, err := db.Exec("DELETE FROM merchant_rules WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func validateMerchantRule(r *MerchantRule) error {
+	r.Merchant = strings.TrimSpace(r.Merchant)
+	if r.Pattern == "" || r.Merchant == "" {
+		return errors.New("pattern and merchant are required")
+	}
+	if _, err := compileMerchantPattern(r.Pattern); err != nil {
+		return errors.New("pattern is not a valid regular expression: " + err.Error())
+	}
+	return nil
+}
+
+// getMerchantSummary groups transactions by normalized merchant, largest
+// totals first.
+func getMerchantSummary(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	normalizer, err := loadMerchantNormalizer()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	where, args := filter.where()
+	transactions, err := queryTransactions(filter.table(), where, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byMerchant := map[string]*MerchantSummary{}
+	for _, t := range transactions {
+		name := normalizer.normalize(t.Description)
+		s, ok := byMerchant[name]
+		if !ok {
+			s = &MerchantSummary{Merchant: name}
+			byMerchant[name] = s
+		}
+		s.Total += t.Amount
+		s.Count++
+	}
+
+	summaries := make([]MerchantSummary, 0, len(byMerchant))
+	for _, s := range byMerchant {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Total.Abs() != summaries[j].Total.Abs() {
+			return summaries[i].Total.Abs() > summaries[j].Total.Abs()
+		}
+		return summaries[i].Merchant < summaries[j].Merchant
+	})
+
+	c.JSON(http.StatusOK, summaries)
+}
+
+// suggestCategory proposes a category for the transaction in the path from
+// the history of its merchant: the most common category among other
+// transactions whose description normalizes to the same merchant, with the
+// share of them that used it as the confidence. Uncategorized transactions
+// don't count as history. Category is null when the merchant has no history
+// or the description has no recognizable merchant.
+func suggestCategory(c *gin.Context) {
+	var id int
+	var description string
+	err := db.QueryRow("SELECT id, description FROM transactions WHERE id = ?", c.Param("id")).Scan(&id, &description)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	normalizer, err := loadMerchantNormalizer()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	merchant := normalizer.normalize(description)
+	if merchant == unknownMerchant {
+		c.JSON(http.StatusOK, gin.H{
+			"transaction_id": id,
+			"merchant":       merchant,
+			"category":       nil,
+			"confidence":     0.0,
+			"matches":        0,
+		})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT description, category FROM transactions
+		WHERE id != ? AND description != '' AND TRIM(category) != '' AND category != ?
+	`, id, cfg.UncategorizedLabel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	matches := 0
+	for rows.Next() {
+		var d, category string
+		if err := rows.Scan(&d, &category); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if normalizer.normalize(d) == merchant {
+			counts[category]++
+			matches++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var best *string
+	bestCount := 0
+	for category, n := range counts {
+		if n > bestCount || (n == bestCount && category < *best) {
+			category := category
+			best, bestCount = &category, n
+		}
+	}
+	confidence := 0.0
+	if matches > 0 {
+		confidence = math.Round(float64(bestCount)/float64(matches)*100) / 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction_id": id,
+		"merchant":       merchant,
+		"category":       best,
+		"confidence":     confidence,
+		"matches":        matches,
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSuggestCategory checks that uncategorized history is ignored and that
+// descriptions without a recognizable merchant get no suggestion.
+func TestSuggestCategory(t *testing.T) {
+	newTestDB(t)
+	previous := cfg.UncategorizedLabel
+	cfg.UncategorizedLabel = "Uncategorized"
+	t.Cleanup(func() { cfg.UncategorizedLabel = previous })
+
+	date := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, tx := range []Transaction{
+		{Description: "COFFEE SHOP 123", Category: "Dining"},
+		{Description: "Coffee Shop 456", Category: "Uncategorized"},
+		{Description: "Coffee Shop 789", Category: "Uncategorized"},
+		{Description: "Coffee Shop 012", Category: "Blank"},
+		{Description: "Coffee Shop 345", Category: "Food"},
+		{Description: "00421187", Category: "Food"},
+		{Description: "#0043", Category: "Food"},
+	} {
+		tx.Date, tx.Amount, tx.Type = date, -500, TypeExpense
+		mustInsert(t, tx)
+	}
+	if _, err := db.Exec("UPDATE transactions SET category = ' ' WHERE category = 'Blank'"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		id       int
+		category string // "" for no suggestion
+		matches  int
+	}{
+		{5, "Dining", 1},
+		{6, "", 0},
+	} {
+		path := fmt.Sprintf("/api/transactions/%d/suggest-category", tt.id)
+		w := serve(http.MethodGet, "/api/transactions/:id/suggest-category", path, "", "", suggestCategory)
+		var got struct {
+			Category *string `json:"category"`
+			Matches  int     `json:"matches"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%d: %s", w.Code, w.Body)
+		}
+		category := ""
+		if got.Category != nil {
+			category = *got.Category
+		}
+		if category != tt.category || got.Matches != tt.matches {
+			t.Errorf("transaction %d: got %s", tt.id, w.Body)
+		}
+	}
+}
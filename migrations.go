@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order and tracked with SQLite's user_version
+// pragma, so each entry runs exactly once per database. Never edit an entry
+// that has shipped; append a new one instead.
+var migrations = []string{
+	`
+		CREATE TABLE IF NOT EXISTS transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date DATE NOT NULL,
+			amount REAL NOT NULL,
+			category TEXT NOT NULL,
+			description TEXT,
+			type TEXT NOT NULL
+		)
+	`,
+	// Store money as integer cents.
+	`
+		ALTER TABLE transactions ADD COLUMN amount_cents INTEGER NOT NULL DEFAULT 0;
+		UPDATE transactions SET amount_cents = CAST(ROUND(amount * 100) AS INTEGER);
+		ALTER TABLE transactions DROP COLUMN amount;
+	`,
+	`
+		CREATE TABLE budgets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			category TEXT NOT NULL UNIQUE,
+			amount_cents INTEGER NOT NULL
+		)
+	`,
+	// Per-month budgets; a NULL month is the default used when a category
+	// has no budget for the month in question.
+	`
+		CREATE TABLE budgets_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			category TEXT NOT NULL,
+			month TEXT,
+			amount_cents INTEGER NOT NULL
+		);
+		INSERT INTO budgets_new (id, category, amount_cents) SELECT id, category, amount_cents FROM budgets;
+		DROP TABLE budgets;
+		ALTER TABLE budgets_new RENAME TO budgets;
+		CREATE UNIQUE INDEX budgets_category_month ON budgets (category, COALESCE(month, ''));
+	`,
+	`
+		CREATE TABLE accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+		ALTER TABLE transactions ADD COLUMN account_id INTEGER REFERENCES accounts (id);
+	`,
+	`
+		CREATE TABLE goals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			target_cents INTEGER NOT NULL,
+			target_date DATE NOT NULL,
+			start_date DATE NOT NULL
+		)
+	`,
+	`
+		CREATE TABLE merchant_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			pattern TEXT NOT NULL,
+			merchant TEXT NOT NULL
+		)
+	`,
+	// Make description NOT NULL so scans into string never fail. SQLite
+	// can't change a column's constraints in place, so the table is rebuilt
+	// and its AUTOINCREMENT counter carried over.
+	`
+		CREATE TABLE transactions_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date DATE NOT NULL,
+			amount_cents INTEGER NOT NULL DEFAULT 0,
+			category TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			type TEXT NOT NULL,
+			account_id INTEGER REFERENCES accounts (id)
+		);
+		INSERT INTO transactions_new (id, date, amount_cents, category, description, type, account_id)
+			SELECT id, date, amount_cents, category, COALESCE(description, ''), type, account_id FROM transactions;
+		DELETE FROM sqlite_sequence WHERE name = 'transactions_new';
+		INSERT INTO sqlite_sequence (name, seq) SELECT 'transactions_new', seq FROM sqlite_sequence WHERE name = 'transactions';
+		DROP TABLE transactions;
+		ALTER TABLE transactions_new RENAME TO transactions;
+	`,
+	// archived_transactions mirrors transactions column for column so rows
+	// can be moved with SELECT *. Any column added to transactions must be
+	// added here in the same migration.
+	`
+		CREATE TABLE archived_transactions (
+			id INTEGER PRIMARY KEY,
+			date DATE NOT NULL,
+			amount_cents INTEGER NOT NULL DEFAULT 0,
+			category TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			type TEXT NOT NULL,
+			account_id INTEGER REFERENCES accounts (id)
+		)
+	`,
+	`
+		CREATE TABLE category_meta (
+			category TEXT PRIMARY KEY,
+			color TEXT NOT NULL DEFAULT '',
+			icon TEXT NOT NULL DEFAULT ''
+		)
+	`,
+	`
+		CREATE TABLE recurring_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			description TEXT NOT NULL DEFAULT '',
+			category TEXT NOT NULL,
+			type TEXT NOT NULL,
+			amount_cents INTEGER NOT NULL,
+			frequency TEXT NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE
+		)
+	`,
+	`
+		CREATE TABLE budget_templates (
+			category TEXT PRIMARY KEY,
+			amount_cents INTEGER NOT NULL
+		);
+		CREATE TABLE budget_template_months (
+			month TEXT PRIMARY KEY
+		)
+	`,
+	`
+		ALTER TABLE transactions ADD COLUMN status TEXT NOT NULL DEFAULT 'cleared';
+		ALTER TABLE archived_transactions ADD COLUMN status TEXT NOT NULL DEFAULT 'cleared'
+	`,
+	`
+		CREATE TABLE closed_periods (
+			month TEXT PRIMARY KEY,
+			closed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	`
+		ALTER TABLE transactions ADD COLUMN budget_category TEXT;
+		ALTER TABLE archived_transactions ADD COLUMN budget_category TEXT
+	`,
+	`
+		CREATE TABLE allocation_rules (
+			bucket TEXT PRIMARY KEY,
+			percent REAL NOT NULL
+		);
+		CREATE TABLE allocations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			income_id INTEGER NOT NULL,
+			bucket TEXT NOT NULL,
+			amount_cents INTEGER NOT NULL,
+			UNIQUE (income_id, bucket)
+		)
+	`,
+	`
+		CREATE TABLE transaction_tags (
+			transaction_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (transaction_id, tag)
+		)
+	`,
+	`
+		ALTER TABLE transactions ADD COLUMN flagged INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE archived_transactions ADD COLUMN flagged INTEGER NOT NULL DEFAULT 0
+	`,
+	`
+		CREATE TABLE attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			transaction_id INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			storage_key TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX attachments_transaction_id ON attachments (transaction_id)
+	`,
+	`
+		CREATE TABLE imported_files (
+			hash TEXT PRIMARY KEY,
+			filename TEXT NOT NULL,
+			imported_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	`
+		ALTER TABLE category_meta ADD COLUMN spending_type TEXT NOT NULL DEFAULT ''
+	`,
+	`
+		ALTER TABLE transactions ADD COLUMN reimbursed INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE archived_transactions ADD COLUMN reimbursed INTEGER NOT NULL DEFAULT 0
+	`,
+	`
+		CREATE TABLE assets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			amount_cents INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE liabilities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			amount_cents INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	`
+		ALTER TABLE transactions ADD COLUMN context TEXT NOT NULL DEFAULT 'personal';
+		ALTER TABLE archived_transactions ADD COLUMN context TEXT NOT NULL DEFAULT 'personal'
+	`,
+}
+
+// migrate brings d up to the latest schema.
+func migrate(d *sql.DB) error {
+	var version int
+	if err := d.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := d.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in integer cents. Amounts are stored and summed as
+// integers so aggregates never pick up floating-point error, and are
+// rendered as decimal numbers in JSON and CSV.
+type Money int64
+
+func (m Money) String() string {
+	v := int64(m)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Float returns the amount in whole currency units. It is only meant for
+// ratios and display, never for further arithmetic on money.
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := parseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func (m Money) MarshalCSV() (string, error) {
+	return m.String(), nil
+}
+
+func (m *Money) UnmarshalCSV(s string) error {
+	v, err := parseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// maxMoneyDigits bounds the whole-unit digits parseMoney accepts, keeping
+// amounts, and sums of many of them, well inside int64 cents.
+const maxMoneyDigits = 15
+
+// parseMoney parses a decimal string such as "-12.34", or "1.5e2" in
+// exponent notation, into cents without going through float64. Digits
+// beyond the second decimal place are rounded half away from zero, or
+// rejected when AMOUNT_PRECISION is "reject"; trailing zeros such as in
+// "12.500" are always accepted.
+func parseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	neg := false
+	digits := s
+	switch digits[0] {
+	case '-':
+		neg = true
+		digits = digits[1:]
+	case '+':
+		digits = digits[1:]
+	}
+	if mantissa, exp, ok := strings.Cut(strings.ToLower(digits), "e"); ok {
+		if digits, ok = shiftDecimal(mantissa, exp); !ok {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) || len(whole) > maxMoneyDigits {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if cfg.RejectFractionalCents && len(frac) > 2 && strings.Trim(frac[2:], "0") != "" {
+		return 0, fractionalCentsError(s)
+	}
+
+	units, _ := strconv.ParseInt(whole, 10, 64)
+	cents := int64(0)
+	for i := 0; i < 2; i++ {
+		cents *= 10
+		if i < len(frac) {
+			cents += int64(frac[i] - '0')
+		}
+	}
+	total := units*100 + cents
+	if len(frac) > 2 && frac[2] >= '5' {
+		total++
+	}
+	if neg {
+		total = -total
+	}
+	return Money(total), nil
+}
+
+// maxMoneyExponent bounds the exponent shiftDecimal accepts; anything
+// larger can only be out of range or, negative, round to nothing.
+const maxMoneyExponent = 2 * maxMoneyDigits
+
+// shiftDecimal rewrites a mantissa such as "1.5" and exponent such as "2"
+// as the plain decimal they denote, "150", so amounts in exponent notation
+// are parsed digit by digit like any other. It reports false for a
+// malformed mantissa or exponent.
+func shiftDecimal(mantissa, exp string) (string, bool) {
+	e, err := strconv.Atoi(exp)
+	if err != nil || e < -maxMoneyExponent || e > maxMoneyExponent {
+		return "", false
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if !isDigits(whole) || !isDigits(frac) || whole+frac == "" {
+		return "", false
+	}
+
+	digits := whole + frac
+	point := len(whole) + e
+	switch {
+	case point <= 0:
+		digits = "." + strings.Repeat("0", -point) + digits
+	case point >= len(digits):
+		digits += strings.Repeat("0", point-len(digits))
+	default:
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if digits = strings.TrimLeft(digits, "0"); digits == "" {
+		digits = "0"
+	}
+	return digits, true
+}
+
+func fractionalCentsError(s string) error {
+	return fmt.Errorf("invalid amount %q: amounts may have at most two decimal places", s)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// divideMoney splits m into n parts, rounding half away from zero. It
+// returns zero when n is zero.
+func divideMoney(m Money, n int) Money {
+	if n == 0 {
+		return 0
+	}
+	q := float64(m) / float64(n)
+	return Money(math.Round(q))
+}
+
+// Sign conventions for moneyFormat.
+const (
+	signMinus = "minus" // "-" on negative amounts only
+	signPlus  = "plus"  // "-" on negatives and "+" on positives
+	signNone  = "none"  // absolute values
+)
+
+// moneyFormat renders amounts for exports whose consumers expect a fixed
+// number of decimals or an explicit sign. The zero value is not usable;
+// defaultMoneyFormat matches Money.String.
+type moneyFormat struct {
+	Decimals int
+	Sign     string
+}
+
+var defaultMoneyFormat = moneyFormat{Decimals: 2, Sign: signMinus}
+
+// maxMoneyDecimals bounds moneyFormat.Decimals; anything past the cents is
+// padding.
+const maxMoneyDecimals = 4
+
+func (f moneyFormat) format(m Money) string {
+	v := int64(m.Abs())
+	if f.Decimals < 2 {
+		unit := pow10(2 - f.Decimals)
+		v = (v + unit/2) / unit
+	} else {
+		v *= pow10(f.Decimals - 2)
+	}
+
+	sign := ""
+	switch {
+	case v == 0 || f.Sign == signNone:
+	case m < 0:
+		sign = "-"
+	case f.Sign == signPlus:
+		sign = "+"
+	}
+
+	if f.Decimals == 0 {
+		return sign + strconv.FormatInt(v, 10)
+	}
+	scale := pow10(f.Decimals)
+	return fmt.Sprintf("%s%d.%0*d", sign, v/scale, f.Decimals, v%scale)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.5", 1250},
+		{"-12.34", -1234},
+		{"+0.01", 1},
+		{".10", 10},
+		{"12.345", 1235},
+		{"-12.345", -1235},
+		{"12.344", 1234},
+		{"  7.00 ", 700},
+		{"1.5e2", 15000},
+		{"-1.5E+2", -15000},
+		{"0.07e0", 7},
+		{"7e-2", 7},
+		{"1234.5e-2", 1235},
+		{"0e5", 0},
+		{"999999999999999.99", 99999999999999999},
+	}
+	for _, tt := range tests {
+		got, err := parseMoney(tt.in)
+		if err != nil {
+			t.Errorf("parseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, in := range []string{"", "-", ".", "abc", "1.2.3", "12,50", "1e", "e5", "1e2.5", "1ee2", "1e20", "-1e20", "1e15", "1e999999999999", "1000000000000000", "NaN", "Inf"} {
+		if got, err := parseMoney(in); err == nil {
+			t.Errorf("parseMoney(%q) = %d, want error", in, got)
+		}
+	}
+}
+
+// TestParseMoneyRejectFractionalCents checks AMOUNT_PRECISION=reject in
+// both plain and exponent notation.
+func TestParseMoneyRejectFractionalCents(t *testing.T) {
+	previous := cfg.RejectFractionalCents
+	cfg.RejectFractionalCents = true
+	t.Cleanup(func() { cfg.RejectFractionalCents = previous })
+
+	for _, tt := range []struct {
+		in   string
+		want Money
+	}{
+		{"12", 1200},
+		{"12.5", 1250},
+		{"12.500", 1250},
+		{"0.07e0", 7},
+		{"1.25e1", 1250},
+	} {
+		if got, err := parseMoney(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseMoney(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"12.999", "-12.999", "1.2999e1", "1e-3"} {
+		if got, err := parseMoney(in); err == nil {
+			t.Errorf("parseMoney(%q) = %d, want error", in, got)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{-1, "-0.01"},
+		{1050, "10.50"},
+		{-123456, "-1234.56"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{0, 1, -1, 10, 1999, -250075, 99999999999999999} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if got != m {
+			t.Errorf("round trip of %d through %s gave %d", int64(m), data, int64(got))
+		}
+	}
+
+	var quoted Money
+	if err := json.Unmarshal([]byte(`"12.34"`), &quoted); err != nil || quoted != 1234 {
+		t.Errorf(`unmarshal "12.34" = %d, %v; want 1234`, int64(quoted), err)
+	}
+	var overflow Money
+	if err := json.Unmarshal([]byte(`1e20`), &overflow); err == nil {
+		t.Errorf("unmarshal 1e20 = %d, want error", int64(overflow))
+	}
+}
+
+// TestSummaryExactTotals adds and imports amounts such as 0.1 and 0.20,
+// which have no exact float64 representation, and checks the summary
+// totals to the cent; any float arithmetic along the way would drift.
+func TestSummaryExactTotals(t *testing.T) {
+	newTestDB(t)
+	for i := 0; i < 500; i++ {
+		for _, amount := range []string{"0.1", `"0.20"`} {
+			body := `{"date": "2024-03-10T12:00:00Z", "amount": ` + amount + `, "category": "Coffee", "type": "expense"}`
+			if w := serve(http.MethodPost, "/api/transactions", "/api/transactions", "application/json", body, addTransaction); w.Code != http.StatusCreated {
+				t.Fatalf("add: got %d: %s", w.Code, w.Body)
+			}
+		}
+	}
+	var csv strings.Builder
+	csv.WriteString("date,amount,category,type\n")
+	for i := 0; i < 500; i++ {
+		csv.WriteString("2024-03-11T12:00:00Z,0.10,Interest,income\n2024-03-11T12:00:00Z,0.20,Interest,income\n")
+	}
+	body, contentType := uploadBody(t, "import.csv", []byte(csv.String()))
+	if w := serve(http.MethodPost, "/api/transactions/import", "/api/transactions/import", contentType, body, importTransactions); w.Code != http.StatusCreated {
+		t.Fatalf("import: got %d: %s", w.Code, w.Body)
+	}
+
+	summaries, err := queryMonthlySummaries("2024-03", "2024-03", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("got %d months, want 1", len(summaries))
+	}
+	s := summaries[0]
+	if s.TotalExpense != 15000 || s.TotalIncome != 15000 || s.Savings != 0 {
+		t.Errorf("got income %s, expense %s, savings %s; want 150.00, 150.00, 0.00", s.TotalIncome, s.TotalExpense, s.Savings)
+	}
+	if got := s.TotalExpense.String(); got != "150.00" {
+		t.Errorf("expense renders as %q, want 150.00", got)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BalanceItem is a manually tracked asset, such as a house or a brokerage
+// account, or liability, such as a mortgage, that transactions don't
+// cover. Amount is always positive; the table it lives in gives its sign.
+type BalanceItem struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Amount    Money     `json:"amount"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// balanceSheet serves the CRUD endpoints of one balance item table.
+type balanceSheet struct {
+	table string
+	noun  string
+}
+
+var (
+	assets      = balanceSheet{table: "assets", noun: "asset"}
+	liabilities = balanceSheet{table: "liabilities", noun: "liability"}
+)
+
+func (s balanceSheet) list(c *gin.Context) {
+	items, err := s.items()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (s balanceSheet) items() ([]BalanceItem, error) {
+	rows, err := db.Query("SELECT id, name, amount_cents, updated_at FROM " + s.table + " ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []BalanceItem{}
+	for rows.Next() {
+		var b BalanceItem
+		if err := rows.Scan(&b.ID, &b.Name, &b.Amount, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, b)
+	}
+	return items, rows.Err()
+}
+
+func (s balanceSheet) add(c *gin.Context) {
+	b, ok := bindBalanceItem(c)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("INSERT INTO "+s.table+" (name, amount_cents) VALUES (?, ?)", b.Name, b.Amount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, _ := result.LastInsertId()
+	s.respond(c, http.StatusCreated, id)
+}
+
+func (s balanceSheet) update(c *gin.Context) {
+	b, ok := bindBalanceItem(c)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(
+		"UPDATE "+s.table+" SET name = ?, amount_cents = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		b.Name, b.Amount, c.Param("id"),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.noun + " not found"})
+		return
+	}
+	s.respond(c, http.StatusOK, c.Param("id"))
+}
+
+func (s balanceSheet) delete(c *gin.Context) {
+	_, err := db.Exec("DELETE FROM "+s.table+" WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respond answers with the stored item, so the client sees updated_at.
+func (s balanceSheet) respond(c *gin.Context, status int, id any) {
+	var b BalanceItem
+	err := db.QueryRow("SELECT id, name, amount_cents, updated_at FROM "+s.table+" WHERE id = ?", id).
+		Scan(&b.ID, &b.Name, &b.Amount, &b.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, b)
+}
+
+// bindBalanceItem reads and validates a balance item from the request
+// body. On failure it has already written the response.
+func bindBalanceItem(c *gin.Context) (BalanceItem, bool) {
+	var b BalanceItem
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return b, false
+	}
+	verr := ValidationError{}
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		verr["name"] = "is required"
+	}
+	if b.Amount < 0 {
+		verr["amount"] = "must not be negative"
+	}
+	if err := verr.err(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr})
+		return b, false
+	}
+	return b, true
+}
+
+// getNetWorth adds the cash balance, computed from every transaction dated
+// today or earlier, archived ones included, to the manually tracked assets
+// and subtracts the liabilities.
+func getNetWorth(c *gin.Context) {
+	var cash Money
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN type IN (?, ?) THEN ABS(amount_cents) ELSE -ABS(amount_cents) END), 0)
+		FROM (
+			SELECT type, amount_cents, date FROM transactions
+			UNION ALL SELECT type, amount_cents, date FROM archived_transactions
+		)
+		WHERE date(date) <= date('now')
+	`, TypeIncome, TypeRefund).Scan(&cash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	assetItems, err := assets.items()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	liabilityItems, err := liabilities.items()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var totalAssets, totalLiabilities Money
+	for _, a := range assetItems {
+		totalAssets += a.Amount
+	}
+	for _, l := range liabilityItems {
+		totalLiabilities += l.Amount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cash":              cash,
+		"assets":            assetItems,
+		"liabilities":       liabilityItems,
+		"total_assets":      totalAssets,
+		"total_liabilities": totalLiabilities,
+		"net_worth":         cash + totalAssets - totalLiabilities,
+	})
+}
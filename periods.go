@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClosedPeriod is a month whose transactions are finalized, for example
+// after filing taxes. Transactions dated in it can't be added or deleted
+// until it is reopened.
+type ClosedPeriod struct {
+	Month    string    `json:"month"`
+	ClosedAt time.Time `json:"closed_at"`
+}
+
+// errPeriodClosed is wrapped by the errors that reject changes to a closed
+// period; handlers answer it with 403.
+var errPeriodClosed = errors.New("period is closed")
+
+func getClosedPeriods(c *gin.Context) {
+	rows, err := db.Query("SELECT month, closed_at FROM closed_periods ORDER BY month")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	periods := []ClosedPeriod{}
+	for rows.Next() {
+		var p ClosedPeriod
+		if err := rows.Scan(&p.Month, &p.ClosedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		periods = append(periods, p)
+	}
+
+	c.JSON(http.StatusOK, periods)
+}
+
+// closePeriod closes the YYYY-MM month in the path. Closing a month that is
+// already closed is a no-op.
+func closePeriod(c *gin.Context) {
+	month := c.Param("month")
+	if !isMonth(month) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "month must be in YYYY-MM format"})
+		return
+	}
+
+	if _, err := db.Exec("INSERT OR IGNORE INTO closed_periods (month) VALUES (?)", month); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var p ClosedPeriod
+	if err := db.QueryRow("SELECT month, closed_at FROM closed_periods WHERE month = ?", month).Scan(&p.Month, &p.ClosedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func reopenPeriod(c *gin.Context) {
+	if _, err := db.Exec("DELETE FROM closed_periods WHERE month = ?", c.Param("month")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// closedMonths returns the set of closed months.
+func closedMonths(q queryer) (map[string]bool, error) {
+	rows, err := q.Query("SELECT month FROM closed_periods")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := map[string]bool{}
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		months[m] = true
+	}
+	return months, rows.Err()
+}
+
+// checkPeriodsOpen returns an error wrapping errPeriodClosed if any of the
+// transactions is dated in a closed month.
+func checkPeriodsOpen(transactions ...*Transaction) error {
+	return checkPeriodsOpenIn(db, transactions...)
+}
+
+// checkPeriodsOpenIn is checkPeriodsOpen reading the closed periods through
+// q, so a check made inside a database transaction sees the same state as
+// the writes that follow it.
+func checkPeriodsOpenIn(q queryer, transactions ...*Transaction) error {
+	closed, err := closedMonths(q)
+	if err != nil {
+		return err
+	}
+	for _, t := range transactions {
+		if month := localMonth(t.Date); closed[month] {
+			return fmt.Errorf("%w: %s; reopen it first", errPeriodClosed, month)
+		}
+	}
+	return nil
+}
+
+// checkNoClosedTransactions fails when any stored transaction falls in a
+// closed month, for operations that replace the whole table.
+func checkNoClosedTransactions() error {
+	return checkNoClosedRows("transactions", "")
+}
+
+// checkNoClosedRows fails when any row of table matched by where, a WHERE
+// clause or "", falls in a closed month, for bulk updates.
+func checkNoClosedRows(table, where string, args ...any) error {
+	var month string
+	err := db.QueryRow(`
+		SELECT month FROM (
+			SELECT strftime('%Y-%m', datetime(date, ?)) AS month FROM `+table+` `+where+`
+		)
+		WHERE month IN (SELECT month FROM closed_periods)
+		LIMIT 1
+	`, append([]any{tzModifier()}, args...)...).Scan(&month)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s; reopen it first", errPeriodClosed, month)
+}
+
+// respondPeriodError answers 403 for a closed period and 500 otherwise.
+func respondPeriodError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errPeriodClosed) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestBulkEditsRespectClosedPeriods checks that merging categories and
+// tagging by filter refuse to touch transactions in a closed month.
+func TestBulkEditsRespectClosedPeriods(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), Amount: -500, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: time.Date(2024, 4, 10, 12, 0, 0, 0, time.UTC), Amount: -700, Category: "Fuel", Type: TypeExpense})
+	if _, err := db.Exec("INSERT INTO closed_periods (month) VALUES ('2024-03')"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		route   string
+		handler gin.HandlerFunc
+		body    string
+		want    int
+	}{
+		{"merge closed", "/api/categories/merge", mergeCategories, `{"from": ["Food"], "into": "Groceries"}`, http.StatusForbidden},
+		{"merge open", "/api/categories/merge", mergeCategories, `{"from": ["Fuel"], "into": "Transport"}`, http.StatusOK},
+		{"tag closed", "/api/transactions/tag-by-filter", tagByFilter, `{"filter": {"from": "2024-03-01"}, "tag": "x"}`, http.StatusForbidden},
+		{"tag open", "/api/transactions/tag-by-filter", tagByFilter, `{"filter": {"from": "2024-04-01"}, "tag": "x"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serve(http.MethodPost, tt.route, tt.route, "application/json", tt.body, tt.handler)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	var food, tagged int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions WHERE category = 'Food'").Scan(&food); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM transaction_tags").Scan(&tagged); err != nil {
+		t.Fatal(err)
+	}
+	if food != 1 || tagged != 1 {
+		t.Errorf("got %d Food transactions and %d tags, want 1 and 1", food, tagged)
+	}
+}
+
+// TestToggleReimbursedClosedPeriod checks that a transaction in a closed
+// month can't be marked reimbursed, since that changes the month's totals.
+func TestToggleReimbursedClosedPeriod(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), Amount: -500, Category: "Travel", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: time.Date(2024, 4, 10, 12, 0, 0, 0, time.UTC), Amount: -700, Category: "Travel", Type: TypeExpense})
+	if _, err := db.Exec("INSERT INTO closed_periods (month) VALUES ('2024-03')"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusForbidden},
+		{"2", http.StatusOK},
+		{"3", http.StatusNotFound},
+	} {
+		path := "/api/transactions/" + tt.id + "/reimbursed"
+		if w := serve(http.MethodPost, "/api/transactions/:id/reimbursed", path, "", "", toggleReimbursed); w.Code != tt.want {
+			t.Errorf("transaction %s: got %d, want %d: %s", tt.id, w.Code, tt.want, w.Body)
+		}
+	}
+	var reimbursed int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions WHERE reimbursed").Scan(&reimbursed); err != nil {
+		t.Fatal(err)
+	}
+	if reimbursed != 1 {
+		t.Errorf("%d transactions reimbursed, want 1", reimbursed)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportQIF writes the filtered transactions in Quicken Interchange Format
+// for older desktop finance apps: a !Type:Bank header followed by one record
+// per transaction with its date (D), amount (T), payee (P) and category (L),
+// each terminated by ^.
+func exportQIF(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	where, args := filter.where()
+	rows, err := db.Query("SELECT "+transactionColumns+" FROM "+filter.table()+" "+where+" ORDER BY date, id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", "application/qif")
+	c.Header("Content-Disposition", "attachment;filename=transactions.qif")
+	c.Status(http.StatusOK)
+
+	w := bufio.NewWriter(c.Writer)
+	w.WriteString("!Type:Bank\n")
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		w.WriteString("D" + t.Date.Format("01/02/2006") + "\n")
+		w.WriteString("T" + t.Amount.String() + "\n")
+		if t.Description != "" {
+			w.WriteString("P" + qifField(t.Description) + "\n")
+		}
+		w.WriteString("L" + qifField(t.Category) + "\n")
+		w.WriteString("^\n")
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+	}
+	if err := w.Flush(); err != nil {
+		c.Error(err)
+	}
+}
+
+// qifField keeps a value on a single line, since QIF is line-oriented.
+func qifField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
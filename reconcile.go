@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reconcileMatch pairs a bank row with the stored transaction it matched.
+type reconcileMatch struct {
+	Bank   *Transaction
+	Stored *Transaction
+}
+
+// reconcile compares an uploaded bank CSV, in the import format, with the
+// stored transactions over the same dates. A bank row matches a stored
+// transaction when their signed amounts differ by at most ?amount_tolerance
+// (default 0.00) and their dates by at most ?days (default 2, to allow for
+// posting delays). Each transaction matches at most once, preferring the
+// closest date.
+//
+// The response lists rows only in the file (missing from the database) and
+// transactions only in the database (possibly erroneous), plus the number
+// that matched. Category and type are optional here since bank exports
+// rarely carry them.
+func reconcile(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "2"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a non-negative integer"})
+		return
+	}
+	tolerance, err := parseMoney(c.DefaultQuery("amount_tolerance", "0"))
+	if err != nil || tolerance < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount_tolerance must be a non-negative amount"})
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bank []*Transaction
+	err = decodeImportCSV(file, opts, func(line int, t *Transaction) error {
+		errs := ValidationError{}
+		if t.Date.IsZero() {
+			errs["date"] = "is required"
+		}
+		if t.Amount == 0 {
+			errs["amount"] = "must not be zero"
+		}
+		if err := errs.err(); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		bank = append(bank, t)
+		return nil
+	})
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	onlyInFile := []*Transaction{}
+	onlyInDB := []Transaction{}
+	if len(bank) == 0 {
+		c.JSON(http.StatusOK, gin.H{"matched": 0, "only_in_file": onlyInFile, "only_in_db": onlyInDB})
+		return
+	}
+
+	sort.SliceStable(bank, func(i, j int) bool { return bank[i].Date.Before(bank[j].Date) })
+	from := bank[0].Date.UTC().AddDate(0, 0, -days).Format("2006-01-02")
+	to := bank[len(bank)-1].Date.UTC().AddDate(0, 0, days).Format("2006-01-02")
+	stored, err := queryTransactions("transactions", "WHERE date(date) >= ? AND date(date) <= ? ORDER BY date, id", from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches := matchTransactions(bank, stored, days, tolerance)
+	matchedBank := map[*Transaction]bool{}
+	matchedStored := map[*Transaction]bool{}
+	for _, m := range matches {
+		matchedBank[m.Bank] = true
+		matchedStored[m.Stored] = true
+	}
+	for _, t := range bank {
+		if !matchedBank[t] {
+			onlyInFile = append(onlyInFile, t)
+		}
+	}
+	for i := range stored {
+		if !matchedStored[&stored[i]] {
+			onlyInDB = append(onlyInDB, stored[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matched":      len(matches),
+		"only_in_file": onlyInFile,
+		"only_in_db":   onlyInDB,
+	})
+}
+
+// matchTransactions greedily pairs each bank row, oldest first, with the
+// unmatched stored transaction closest in date, then in amount.
+func matchTransactions(bank []*Transaction, stored []Transaction, days int, tolerance Money) []reconcileMatch {
+	// Candidates are found by binary search over stored sorted by amount.
+	byAmount := make([]*Transaction, len(stored))
+	for i := range stored {
+		byAmount[i] = &stored[i]
+	}
+	sort.SliceStable(byAmount, func(i, j int) bool { return byAmount[i].Amount < byAmount[j].Amount })
+
+	used := make([]bool, len(byAmount))
+	var matches []reconcileMatch
+	for _, b := range bank {
+		best := -1
+		var bestDays int
+		var bestDiff Money
+		start := sort.Search(len(byAmount), func(i int) bool { return byAmount[i].Amount >= b.Amount-tolerance })
+		for i := start; i < len(byAmount) && byAmount[i].Amount <= b.Amount+tolerance; i++ {
+			if used[i] {
+				continue
+			}
+			d := dayDiff(b.Date, byAmount[i].Date)
+			if d > days {
+				continue
+			}
+			diff := (byAmount[i].Amount - b.Amount).Abs()
+			if best == -1 || d < bestDays || (d == bestDays && diff < bestDiff) {
+				best, bestDays, bestDiff = i, d, diff
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			matches = append(matches, reconcileMatch{Bank: b, Stored: byAmount[best]})
+		}
+	}
+	return matches
+}
+
+// dayDiff is the number of calendar days between a and b, in UTC.
+func dayDiff(a, b time.Time) int {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	d := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC).Sub(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC))
+	if d < 0 {
+		d = -d
+	}
+	return int(d.Hours() / 24)
+}
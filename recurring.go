@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecurringTransaction describes a transaction that repeats on a fixed
+// schedule, such as rent or a salary, starting on StartDate and stopping
+// after EndDate when one is set.
+type RecurringTransaction struct {
+	ID          int        `json:"id"`
+	Description string     `json:"description"`
+	Category    string     `json:"category"`
+	Type        string     `json:"type"`
+	Amount      Money      `json:"amount"`
+	Frequency   string     `json:"frequency"`
+	StartDate   time.Time  `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`
+}
+
+var recurringFrequencies = []string{"weekly", "monthly", "yearly"}
+
+func queryRecurring() ([]RecurringTransaction, error) {
+	rows, err := db.Query(`
+		SELECT id, description, category, type, amount_cents, frequency, start_date, end_date
+		FROM recurring_transactions
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurring := []RecurringTransaction{}
+	for rows.Next() {
+		var r RecurringTransaction
+		err := rows.Scan(&r.ID, &r.Description, &r.Category, &r.Type, &r.Amount, &r.Frequency, &r.StartDate, &r.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		recurring = append(recurring, r)
+	}
+	return recurring, rows.Err()
+}
+
+func getRecurring(c *gin.Context) {
+	recurring, err := queryRecurring()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, recurring)
+}
+
+func addRecurring(c *gin.Context) {
+	var r RecurringTransaction
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRecurring(&r); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO recurring_transactions (description, category, type, amount_cents, frequency, start_date, end_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, r.Description, r.Category, r.Type, r.Amount, r.Frequency, r.StartDate, r.EndDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	r.ID = int(id)
+	c.JSON(http.StatusCreated, r)
+}
+
+func deleteRecurring(c *gin.Context) {
+	_, err := db.Exec("DELETE FROM recurring_transactions WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func validateRecurring(r *RecurringTransaction) error {
+	r.Category = strings.TrimSpace(r.Category)
+	switch {
+	case r.Category == "":
+		return errors.New("category is required")
+	case !isTransactionType(r.Type):
+		return errors.New("type " + transactionTypeError())
+	case r.Amount == 0:
+		return errors.New("amount must not be zero")
+	case r.StartDate.IsZero():
+		return errors.New("start_date is required")
+	case r.EndDate != nil && r.EndDate.Before(r.StartDate):
+		return errors.New("end_date must not be before start_date")
+	}
+	valid := false
+	for _, f := range recurringFrequencies {
+		valid = valid || r.Frequency == f
+	}
+	if !valid {
+		return errors.New("frequency must be one of " + strings.Join(recurringFrequencies, ", "))
+	}
+	// Stored with the same sign convention as transactions.
+	r.Amount = r.Amount.Abs()
+	if r.Type == TypeExpense {
+		r.Amount = -r.Amount
+	}
+	return nil
+}
+
+// occurrencesIn counts how many times r falls within [from, to).
+func (r RecurringTransaction) occurrencesIn(from, to time.Time) int {
+	n := 0
+	for i, d := 0, r.StartDate; d.Before(to); i, d = i+1, r.nth(i+1) {
+		if r.EndDate != nil && d.After(*r.EndDate) {
+			break
+		}
+		if !d.Before(from) {
+			n++
+		}
+	}
+	return n
+}
+
+// nth returns the date of the i-th occurrence, counting StartDate as 0.
+// Dates are always derived from StartDate rather than the previous
+// occurrence, so rounding at month ends doesn't accumulate.
+func (r RecurringTransaction) nth(i int) time.Time {
+	switch r.Frequency {
+	case "weekly":
+		return r.StartDate.AddDate(0, 0, 7*i)
+	case "yearly":
+		return r.StartDate.AddDate(i, 0, 0)
+	default:
+		return r.StartDate.AddDate(0, i, 0)
+	}
+}
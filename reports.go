@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MonthlyReport is the summary sent by POST /api/reports/monthly/send.
+type MonthlyReport struct {
+	Month         string            `json:"month"`
+	Currency      string            `json:"currency"`
+	TotalIncome   Money             `json:"total_income"`
+	TotalExpense  Money             `json:"total_expense"`
+	Savings       Money             `json:"savings"`
+	TopCategories []CategorySummary `json:"top_categories"`
+}
+
+// reportTopCategories is how many expense categories a report lists.
+const reportTopCategories = 5
+
+// notifier delivers a monthly report. To add a channel, implement it and
+// select it in newNotifier.
+type notifier interface {
+	notify(ctx context.Context, r MonthlyReport) error
+}
+
+// newNotifier returns the notifier configured in the environment, or nil if
+// there is none. A webhook takes precedence over SMTP.
+func newNotifier() notifier {
+	switch {
+	case cfg.ReportWebhookURL != "":
+		return webhookNotifier{url: cfg.ReportWebhookURL}
+	case cfg.SMTPAddr != "" && len(cfg.ReportEmailTo) > 0:
+		return smtpNotifier{
+			addr:     cfg.SMTPAddr,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+			from:     cfg.ReportEmailFrom,
+			to:       cfg.ReportEmailTo,
+		}
+	}
+	return nil
+}
+
+// sendMonthlyReport composes the report for ?month= (YYYY-MM, default the
+// current month) and hands it to the configured notifier. It responds with
+// the report that was sent.
+func sendMonthlyReport(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	n := newNotifier()
+	if n == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no report delivery is configured; set REPORT_WEBHOOK_URL or SMTP_ADDR and REPORT_EMAIL_TO"})
+		return
+	}
+
+	report, err := buildMonthlyReport(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.ReportTimeout)
+	defer cancel()
+	if err := n.notify(ctx, report); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func buildMonthlyReport(month string) (MonthlyReport, error) {
+	report := MonthlyReport{Month: month, Currency: cfg.Currency, TopCategories: []CategorySummary{}}
+
+	summaries, err := queryMonthlySummaries(month, month, 0, false)
+	if err != nil {
+		return report, err
+	}
+	if len(summaries) > 0 {
+		report.TotalIncome = summaries[0].TotalIncome
+		report.TotalExpense = summaries[0].TotalExpense
+		report.Savings = summaries[0].Savings
+	}
+
+	start, _ := time.Parse("2006-01", month)
+	// Filtering on type in the query would drop refunds, which the summary
+	// reports as expense netted against their category.
+	all, err := queryCategorySummary(transactionFilter{
+		From: start.Format("2006-01-02"),
+		To:   start.AddDate(0, 1, -1).Format("2006-01-02"),
+	})
+	if err != nil {
+		return report, err
+	}
+	var categories []CategorySummary
+	for _, s := range all {
+		if s.Type == TypeExpense {
+			categories = append(categories, s)
+		}
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Total.Abs() > categories[j].Total.Abs()
+	})
+	if len(categories) > reportTopCategories {
+		categories = categories[:reportTopCategories]
+	}
+	report.TopCategories = append(report.TopCategories, categories...)
+	return report, nil
+}
+
+// MonthEndReport is the formal record of a month's books, meant to be
+// archived when the month is closed. Balances are the net of all income,
+// refunds and expenses up to the start and end of the month.
+type MonthEndReport struct {
+	Month          string            `json:"month"`
+	Currency       string            `json:"currency"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+	Closed         bool              `json:"closed"`
+	OpeningBalance Money             `json:"opening_balance"`
+	TotalIncome    Money             `json:"total_income"`
+	TotalExpense   Money             `json:"total_expense"`
+	ClosingBalance Money             `json:"closing_balance"`
+	Categories     []CategorySummary `json:"categories"`
+}
+
+// getMonthEndReport builds the month-end report for ?month= (YYYY-MM,
+// default the current month). The opening balance counts archived
+// transactions too, so archiving doesn't change it.
+func getMonthEndReport(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := MonthEndReport{Month: month, Currency: cfg.Currency, GeneratedAt: time.Now().UTC()}
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN type IN (?, ?) THEN ABS(amount_cents) ELSE -ABS(amount_cents) END), 0),
+			EXISTS (SELECT 1 FROM closed_periods WHERE month = ?)
+		FROM (
+			SELECT type, amount_cents, date, reimbursed FROM transactions
+			UNION ALL SELECT type, amount_cents, date, reimbursed FROM archived_transactions
+		)
+		WHERE NOT reimbursed AND strftime('%Y-%m', datetime(date, ?)) < ?
+	`, TypeIncome, TypeRefund, month, tzModifier(), month).Scan(&report.OpeningBalance, &report.Closed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries, err := queryMonthlySummaries(month, month, 0, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(summaries) > 0 {
+		report.TotalIncome = summaries[0].TotalIncome
+		report.TotalExpense = summaries[0].TotalExpense
+	}
+	report.ClosingBalance = report.OpeningBalance + report.TotalIncome - report.TotalExpense
+
+	start, _ := time.Parse("2006-01", month)
+	report.Categories, err = queryCategorySummary(transactionFilter{
+		From: start.Format("2006-01-02"),
+		To:   start.AddDate(0, 1, -1).Format("2006-01-02"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if report.Categories == nil {
+		report.Categories = []CategorySummary{}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// webhookNotifier POSTs the report as JSON.
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) notify(ctx context.Context, r MonthlyReport) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier emails the report as plain text. Authentication is used only
+// when a username is set.
+type smtpNotifier struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s smtpNotifier) notify(ctx context.Context, r MonthlyReport) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: Financial summary for %s\r\n", r.Month)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Income:   %s %s\r\n", r.TotalIncome, r.Currency)
+	fmt.Fprintf(&msg, "Expenses: %s %s\r\n", r.TotalExpense, r.Currency)
+	fmt.Fprintf(&msg, "Savings:  %s %s\r\n", r.Savings, r.Currency)
+	if len(r.TopCategories) > 0 {
+		msg.WriteString("\r\nTop categories:\r\n")
+		for _, cat := range r.TopCategories {
+			fmt.Fprintf(&msg, "  %s: %s %s (%.2f%%)\r\n", cat.Category, cat.Total.Abs(), r.Currency, cat.Percentage)
+		}
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	// smtp.SendMail takes no context, so honour the deadline by abandoning
+	// the send rather than interrupting it.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, auth, s.from, s.to, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("smtp: %w", ctx.Err())
+	}
+}
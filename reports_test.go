@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestMonthEndOpeningBalanceKeepsArchived checks that archiving old
+// transactions leaves the month-end opening balance unchanged.
+func TestMonthEndOpeningBalanceKeepsArchived(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Now().AddDate(-5, 0, 0), Amount: 10000, Category: "Salary", Type: TypeIncome})
+	mustInsert(t, Transaction{Date: time.Now().AddDate(0, -1, 0), Amount: -3000, Category: "Food", Type: TypeExpense})
+
+	path := "/api/reports/month-end?month=" + localMonth(time.Now())
+	openingBalance := func() Money {
+		t.Helper()
+		w := serve(http.MethodGet, "/api/reports/month-end", path, "", "", getMonthEndReport)
+		var report MonthEndReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%d: %s", w.Code, w.Body)
+		}
+		return report.OpeningBalance
+	}
+
+	before := openingBalance()
+	if before != 7000 {
+		t.Fatalf("opening balance = %s, want 70.00", before)
+	}
+	if n, err := archiveOlderThan(2); err != nil || n != 1 {
+		t.Fatalf("archived %d (%v), want 1", n, err)
+	}
+	if after := openingBalance(); after != before {
+		t.Errorf("opening balance after archiving = %s, want %s", after, before)
+	}
+}
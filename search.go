@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryMatch is a category whose name matched a search, with its totals
+// across all transactions.
+type CategoryMatch struct {
+	Category string `json:"category"`
+	Type     string `json:"type"`
+	Total    Money  `json:"total"`
+	Count    int    `json:"count"`
+}
+
+// search answers the omnibox: categories whose name contains ?q= and the
+// newest transactions, up to ?limit= (default 20), whose description
+// contains it, both case-insensitively.
+func search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			category,
+			CASE WHEN type = ? THEN ? ELSE type END,
+			SUM(CASE WHEN type = ? THEN ABS(amount_cents) ELSE amount_cents END),
+			COUNT(*)
+		FROM transactions
+		WHERE category LIKE ? ESCAPE '\'
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, TypeRefund, TypeExpense, TypeRefund, "%"+likeEscaper.Replace(q)+"%")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	categories := []CategoryMatch{}
+	for rows.Next() {
+		var m CategoryMatch
+		if err := rows.Scan(&m.Category, &m.Type, &m.Total, &m.Count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		categories = append(categories, m)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	where, args := transactionFilter{Search: q}.where()
+	transactions, err := queryTransactions("transactions", where+" ORDER BY date DESC, id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if transactions == nil {
+		transactions = []Transaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":        q,
+		"categories":   categories,
+		"transactions": transactions,
+	})
+}
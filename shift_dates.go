@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxShiftDays bounds a date shift; larger corrections are more likely a
+// mistake than a systematic offset.
+const maxShiftDays = 366
+
+type shiftDatesRequest struct {
+	Filter transactionFilter `json:"filter"`
+	Days   int               `json:"days"`
+}
+
+// shiftDates moves every transaction matching the filter by a number of
+// days, for fixing a statement imported with off-by-one dates, and answers
+// with the count shifted. Times of day are kept. It is refused when a
+// transaction would move into or out of a closed period.
+func shiftDates(c *gin.Context) {
+	var req shiftDatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Filter.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Days == 0 || req.Days < -maxShiftDays || req.Days > maxShiftDays {
+		msg := fmt.Sprintf("must be a non-zero number of days between -%d and %d", maxShiftDays, maxShiftDays)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "days: " + msg, "fields": ValidationError{"days": msg}})
+		return
+	}
+
+	where, args := req.Filter.where()
+	var shifted int
+	err := withTx(func(tx *sql.Tx) error {
+		matching, err := queryTransactionsIn(tx, "transactions", where, args...)
+		if err != nil {
+			return err
+		}
+		affected := make([]*Transaction, 0, 2*len(matching))
+		moved := make([]Transaction, len(matching))
+		for i := range matching {
+			moved[i] = matching[i]
+			moved[i].Date = matching[i].Date.AddDate(0, 0, req.Days)
+			affected = append(affected, &matching[i], &moved[i])
+		}
+		if err := checkPeriodsOpenIn(tx, affected...); err != nil {
+			return err
+		}
+
+		// Shifted in Go so the driver stores the dates in its own layout,
+		// fractional seconds included.
+		for _, t := range moved {
+			if _, err := tx.Exec("UPDATE transactions SET date = ? WHERE id = ?", t.Date, t.ID); err != nil {
+				return err
+			}
+		}
+		shifted = len(moved)
+		return nil
+	})
+	if err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shifted": shifted})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestShiftDates checks that a shift keeps the time of day down to the
+// fractional seconds and is refused as a whole when any transaction would
+// land in a closed period.
+func TestShiftDates(t *testing.T) {
+	newTestDB(t)
+	date := time.Date(2024, 3, 10, 12, 34, 56, 789123000, time.UTC)
+	mustInsert(t, Transaction{Date: date, Amount: -500, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: date.AddDate(0, 0, 15), Amount: -700, Category: "Food", Type: TypeExpense})
+
+	dateOf := func(id int) time.Time {
+		t.Helper()
+		var d time.Time
+		if err := db.QueryRow("SELECT date FROM transactions WHERE id = ?", id).Scan(&d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	w := serve(http.MethodPost, "/api/transactions/shift-dates", "/api/transactions/shift-dates", "application/json",
+		`{"filter": {"to": "2024-03-10"}, "days": 2}`, shiftDates)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if got, want := dateOf(1), date.AddDate(0, 0, 2); !got.Equal(want) {
+		t.Errorf("shifted date = %s, want %s", got, want)
+	}
+
+	if _, err := db.Exec("INSERT INTO closed_periods (month) VALUES ('2024-04')"); err != nil {
+		t.Fatal(err)
+	}
+	w = serve(http.MethodPost, "/api/transactions/shift-dates", "/api/transactions/shift-dates", "application/json",
+		`{"days": 10}`, shiftDates)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("shift into a closed period: got %d: %s", w.Code, w.Body)
+	}
+	if got, want := dateOf(1), date.AddDate(0, 0, 2); !got.Equal(want) {
+		t.Errorf("date after refused shift = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Storage holds attachment files under opaque keys.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+
+	// PresignedURL returns a temporary URL the client can download the file
+	// from directly, or "" when the storage can't issue one and the bytes
+	// must be served through the API.
+	PresignedURL(key, filename string, expires time.Duration) (string, error)
+}
+
+var storage Storage
+
+// newStorage returns S3 storage when S3_BUCKET is set and local disk
+// storage under ATTACHMENTS_DIR otherwise.
+func newStorage() Storage {
+	if cfg.S3Bucket != "" {
+		return &s3Storage{
+			bucket:       cfg.S3Bucket,
+			region:       cfg.S3Region,
+			endpoint:     cfg.S3Endpoint,
+			accessKey:    cfg.S3AccessKeyID,
+			secretKey:    cfg.S3SecretAccessKey,
+			sessionToken: cfg.S3SessionToken,
+		}
+	}
+	return localStorage{dir: cfg.AttachmentsDir}
+}
+
+// errObjectNotFound is returned by Open for a key that isn't stored.
+var errObjectNotFound = errors.New("attachment file not found")
+
+// localStorage keeps files in a directory on disk.
+type localStorage struct {
+	dir string
+}
+
+func (s localStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s localStorage) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(p)
+		return err
+	}
+	return f.Close()
+}
+
+func (s localStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errObjectNotFound
+	}
+	return f, err
+}
+
+func (s localStorage) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (localStorage) PresignedURL(string, string, time.Duration) (string, error) {
+	return "", nil
+}
+
+// s3Storage keeps files in an S3 bucket, signing requests with AWS
+// Signature Version 4. Endpoint, when set, points at an S3-compatible
+// service such as MinIO and switches to path-style addressing.
+type s3Storage struct {
+	bucket       string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// unsignedPayload lets requests skip hashing the body, which S3 permits
+// over HTTPS.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// objectURL is the URL of key, without a query string.
+func (s *s3Storage) objectURL(key string) *url.URL {
+	if s.endpoint != "" {
+		u, _ := url.Parse(strings.TrimSuffix(s.endpoint, "/"))
+		u.Path += "/" + s.bucket + "/" + key
+		return u
+	}
+	return &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if errors.Is(err, errObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for key and fails on any non-2xx response.
+func (s *s3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.sessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var headers strings.Builder
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = u.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	signature := s.sign(now, method, u.EscapedPath(), "", headers.String(), signedHeaders, unsignedPayload)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signedHeaders, signature,
+	))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errObjectNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s returned %s: %s", method, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// PresignedURL returns a GET URL valid for expires that downloads the file
+// as filename.
+func (s *s3Storage) PresignedURL(key, filename string, expires time.Duration) (string, error) {
+	return s.presign(s.objectURL(key), map[string]string{
+		"response-content-disposition": fmt.Sprintf("attachment; filename=%q", filename),
+	}, expires, time.Now().UTC()), nil
+}
+
+// presign returns u with a query string signing a GET of it, made at now
+// and valid for expires. params are added to the signed query.
+func (s *s3Storage) presign(u *url.URL, params map[string]string, expires time.Duration, now time.Time) string {
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.accessKey + "/" + s.scope(now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       fmt.Sprint(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if s.sessionToken != "" {
+		query["X-Amz-Security-Token"] = s.sessionToken
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	q := canonicalQuery(query)
+	signature := s.sign(now, http.MethodGet, u.EscapedPath(), q, "host:"+u.Host+"\n", "host", unsignedPayload)
+	signed := *u
+	signed.RawQuery = q + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// canonicalQuery renders query sorted by name with SigV4 escaping.
+func canonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = awsEscape(k) + "=" + awsEscape(query[k])
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (s *s3Storage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// sign computes the Signature Version 4 signature of a request whose body
+// hashes to payloadHash, or unsignedPayload.
+func (s *s3Storage) sign(t time.Time, method, path, query, headers, signedHeaders, payloadHash string) string {
+	canonical := strings.Join([]string{method, path, query, headers, signedHeaders, payloadHash}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but the RFC 3986 unreserved
+// characters, as SigV4 requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
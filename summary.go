@@ -0,0 +1,551 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryAverage describes the size of individual transactions in a
+// category. Amounts are absolute values so expenses read as sizes.
+type CategoryAverage struct {
+	Category string `json:"category"`
+	Type     string `json:"type"`
+	Count    int    `json:"count"`
+	Min      Money  `json:"min"`
+	Max      Money  `json:"max"`
+	Average  Money  `json:"average"`
+}
+
+// getCategoryAverages reports the count, min, max and average size of the
+// filtered transactions per category and type. Reimbursed expenses are left
+// out, as they are from every spending total.
+func getCategoryAverages(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conds, args := filter.conditions()
+	conds = append(conds, "NOT (type = ? AND reimbursed)")
+	args = append(args, TypeExpense)
+	rows, err := db.Query(`
+		SELECT
+			category,
+			type,
+			COUNT(*),
+			MIN(ABS(amount_cents)),
+			MAX(ABS(amount_cents)),
+			SUM(ABS(amount_cents))
+		FROM `+filter.table()+`
+		`+whereClause(conds)+`
+		GROUP BY category, type
+		ORDER BY type, category
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	averages := []CategoryAverage{}
+	for rows.Next() {
+		var a CategoryAverage
+		var total Money
+		if err := rows.Scan(&a.Category, &a.Type, &a.Count, &a.Min, &a.Max, &total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		a.Average = divideMoney(total, a.Count)
+		averages = append(averages, a)
+	}
+
+	c.JSON(http.StatusOK, averages)
+}
+
+// maxRangeMonths caps how many months a single range summary may span.
+const maxRangeMonths = 120
+
+// getRangeSummary returns the monthly breakdown for every month between the
+// from and to query parameters (YYYY-MM, inclusive), oldest first, with
+// months that have no transactions reported as zero, plus a grand total.
+func getRangeSummary(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if !isMonth(from) || !isMonth(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required in YYYY-MM format"})
+		return
+	}
+	if from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	months := monthsBetween(from, to)
+	if len(months) > maxRangeMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range may span at most %d months", maxRangeMonths)})
+		return
+	}
+
+	breakdown, total, err := rangeBreakdown(from, to, c.Query("cleared_only") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":   from,
+		"to":     to,
+		"months": breakdown,
+		"total":  total,
+	})
+}
+
+// getFiscalYearSummary aggregates the fiscal year named by the year query
+// parameter. Fiscal years are named after the calendar year they end in, so
+// with a July start FY2024 runs from 2023-07 to 2024-06.
+func getFiscalYearSummary(c *gin.Context) {
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil || year < 1 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year is required"})
+		return
+	}
+
+	start := time.Date(year, time.Month(cfg.FiscalYearStartMonth), 1, 0, 0, 0, 0, time.UTC)
+	if cfg.FiscalYearStartMonth != 1 {
+		start = start.AddDate(-1, 0, 0)
+	}
+	from := start.Format("2006-01")
+	to := start.AddDate(0, 11, 0).Format("2006-01")
+
+	breakdown, total, err := rangeBreakdown(from, to, c.Query("cleared_only") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":          year,
+		"from":          from,
+		"to":            to,
+		"total_income":  total.TotalIncome,
+		"total_expense": total.TotalExpense,
+		"savings":       total.Savings,
+		"months":        breakdown,
+	})
+}
+
+// SavingsRate is a month's savings as a percentage of its income. Rate is
+// null, not 0, for months without income, where a rate is undefined.
+type SavingsRate struct {
+	Month       string   `json:"month"`
+	SavingsRate *float64 `json:"savings_rate"`
+}
+
+// getSavingsRateTrend returns the savings rate of each of the last ?months=
+// months (default 12), oldest first, ending with the current month.
+func getSavingsRateTrend(c *gin.Context) {
+	breakdown, ok := trailingBreakdown(c)
+	if !ok {
+		return
+	}
+
+	trend := make([]SavingsRate, len(breakdown))
+	for i, s := range breakdown {
+		trend[i].Month = s.Month
+		trend[i].SavingsRate = ratioPct(s.Savings, s.TotalIncome)
+	}
+
+	c.JSON(http.StatusOK, trend)
+}
+
+// ExpenseRatio is a month's expenses divided by its income, so 0.8 means
+// 80% of income was spent. Ratio is null for months without income.
+type ExpenseRatio struct {
+	Month string   `json:"month"`
+	Ratio *float64 `json:"ratio"`
+}
+
+// getExpenseRatio returns the expense-to-income ratio of each of the last
+// ?months= months (default 12), oldest first, ending with the current month.
+func getExpenseRatio(c *gin.Context) {
+	breakdown, ok := trailingBreakdown(c)
+	if !ok {
+		return
+	}
+
+	ratios := make([]ExpenseRatio, len(breakdown))
+	for i, s := range breakdown {
+		ratios[i].Month = s.Month
+		if s.TotalIncome != 0 {
+			r := math.Round(float64(s.TotalExpense)/float64(s.TotalIncome)*10000) / 10000
+			ratios[i].Ratio = &r
+		}
+	}
+
+	c.JSON(http.StatusOK, ratios)
+}
+
+// trailingBreakdown reads ?months= (default 12) and ?cleared_only= and
+// returns the zero-filled breakdown of that many months ending with the
+// current one. On failure it has already written the response.
+func trailingBreakdown(c *gin.Context) ([]MonthlySummary, bool) {
+	from, to, ok := trailingMonths(c)
+	if !ok {
+		return nil, false
+	}
+	breakdown, _, err := rangeBreakdown(from, to, c.Query("cleared_only") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return breakdown, true
+}
+
+// trailingMonths reads ?months= (default 12) and returns the YYYY-MM bounds
+// of that many months ending with the current one. On failure it has
+// already written the response.
+func trailingMonths(c *gin.Context) (from, to string, ok bool) {
+	n, err := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if err != nil || n < 1 || n > maxRangeMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("months must be between 1 and %d", maxRangeMonths)})
+		return "", "", false
+	}
+	end, _ := time.Parse("2006-01", localMonth(time.Now()))
+	return end.AddDate(0, 1-n, 0).Format("2006-01"), end.Format("2006-01"), true
+}
+
+// ratioPct is part as a percentage of whole rounded to two decimals, or nil
+// when whole is zero.
+func ratioPct(part, whole Money) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	pct := math.Round(float64(part)/float64(whole)*10000) / 100
+	return &pct
+}
+
+// SpendingSplit divides a month's expenses, net of refunds, by the spending
+// type of their category. Unclassified covers categories with no spending
+// type set.
+type SpendingSplit struct {
+	Month         string `json:"month"`
+	Fixed         Money  `json:"fixed"`
+	Discretionary Money  `json:"discretionary"`
+	Unclassified  Money  `json:"unclassified"`
+}
+
+// getFixedVsDiscretionary splits each of the last ?months= months (default
+// 12), oldest first, into fixed and discretionary spending as classified in
+// the category metadata.
+func getFixedVsDiscretionary(c *gin.Context) {
+	from, to, ok := trailingMonths(c)
+	if !ok {
+		return
+	}
+
+	tz := tzModifier()
+	rows, err := db.Query(`
+		SELECT
+			strftime('%Y-%m', datetime(date, ?)),
+			COALESCE((SELECT spending_type FROM category_meta m WHERE m.category = transactions.category), ''),
+			SUM(CASE WHEN type = ? THEN -ABS(amount_cents) ELSE ABS(amount_cents) END)
+		FROM transactions
+		WHERE type IN (?, ?) AND NOT reimbursed AND strftime('%Y-%m', datetime(date, ?)) BETWEEN ? AND ?
+		GROUP BY 1, 2
+	`, tz, TypeRefund, TypeExpense, TypeRefund, tz, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	months := monthsBetween(from, to)
+	splits := make([]SpendingSplit, len(months))
+	index := map[string]int{}
+	for i, m := range months {
+		splits[i].Month = m
+		index[m] = i
+	}
+	var total SpendingSplit
+	for rows.Next() {
+		var month, kind string
+		var amount Money
+		if err := rows.Scan(&month, &kind, &amount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s := &splits[index[month]]
+		switch kind {
+		case SpendingFixed:
+			s.Fixed += amount
+			total.Fixed += amount
+		case SpendingDiscretionary:
+			s.Discretionary += amount
+			total.Discretionary += amount
+		default:
+			s.Unclassified += amount
+			total.Unclassified += amount
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	total.Month = "total"
+	c.JSON(http.StatusOK, gin.H{"months": splits, "total": total})
+}
+
+// ContextTotals are one context's income, spending net of refunds, and
+// savings.
+type ContextTotals struct {
+	Income  Money `json:"income"`
+	Expense Money `json:"expense"`
+	Savings Money `json:"savings"`
+}
+
+func (t *ContextTotals) add(income, expense Money) {
+	t.Income += income
+	t.Expense += expense
+	t.Savings += income - expense
+}
+
+// ContextSplit divides a month's totals into business and personal.
+type ContextSplit struct {
+	Month    string        `json:"month"`
+	Business ContextTotals `json:"business"`
+	Personal ContextTotals `json:"personal"`
+}
+
+// getContextSummary splits each of the last ?months= months (default 12),
+// oldest first, into business and personal totals, counted the same way as
+// the monthly summary. The category summary accepts ?context= for a
+// per-category breakdown of either.
+func getContextSummary(c *gin.Context) {
+	from, to, ok := trailingMonths(c)
+	if !ok {
+		return
+	}
+
+	tz := tzModifier()
+	rows, err := db.Query(`
+		SELECT
+			strftime('%Y-%m', datetime(date, ?)),
+			context,
+			SUM(CASE WHEN type = ? THEN amount_cents ELSE 0 END),
+			SUM(CASE WHEN type = ? THEN ABS(amount_cents) WHEN type = ? THEN -ABS(amount_cents) ELSE 0 END)
+		FROM transactions
+		WHERE NOT reimbursed AND strftime('%Y-%m', datetime(date, ?)) BETWEEN ? AND ?
+		GROUP BY 1, 2
+	`, tz, TypeIncome, TypeExpense, TypeRefund, tz, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	months := monthsBetween(from, to)
+	splits := make([]ContextSplit, len(months))
+	index := map[string]int{}
+	for i, m := range months {
+		splits[i].Month = m
+		index[m] = i
+	}
+	total := ContextSplit{Month: "total"}
+	for rows.Next() {
+		var month, context string
+		var income, expense Money
+		if err := rows.Scan(&month, &context, &income, &expense); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s := &splits[index[month]]
+		if context == ContextBusiness {
+			s.Business.add(income, expense)
+			total.Business.add(income, expense)
+		} else {
+			s.Personal.add(income, expense)
+			total.Personal.add(income, expense)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"months": splits, "total": total})
+}
+
+// rangeBreakdown returns one summary per month in [from, to], oldest first
+// and zero-filled, along with their grand total.
+func rangeBreakdown(from, to string, clearedOnly bool) ([]MonthlySummary, MonthlySummary, error) {
+	total := MonthlySummary{Month: "total"}
+	summaries, err := queryMonthlySummaries(from, to, 0, clearedOnly)
+	if err != nil {
+		return nil, total, err
+	}
+
+	byMonth := map[string]MonthlySummary{}
+	for _, s := range summaries {
+		byMonth[s.Month] = s
+	}
+	months := monthsBetween(from, to)
+	breakdown := make([]MonthlySummary, 0, len(months))
+	for _, m := range months {
+		s, ok := byMonth[m]
+		if !ok {
+			s = MonthlySummary{Month: m}
+		}
+		total.TotalIncome += s.TotalIncome
+		total.TotalExpense += s.TotalExpense
+		total.Savings += s.Savings
+		breakdown = append(breakdown, s)
+	}
+	return breakdown, total, nil
+}
+
+// monthsBetween lists the YYYY-MM months from first to last inclusive. Both
+// must already be valid months.
+func monthsBetween(first, last string) []string {
+	start, _ := time.Parse("2006-01", first)
+	end, _ := time.Parse("2006-01", last)
+	var months []string
+	for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+		months = append(months, m.Format("2006-01"))
+	}
+	return months
+}
+
+// Comparison sets an amount against the same amount in an earlier period.
+// ChangePct is nil when the earlier amount was zero, in which case Status is
+// "new" rather than an infinite percentage.
+type Comparison struct {
+	Category  string   `json:"category,omitempty"`
+	Current   Money    `json:"current"`
+	Previous  Money    `json:"previous"`
+	ChangePct *float64 `json:"change_pct"`
+	Status    string   `json:"status"`
+}
+
+func compareAmounts(current, previous Money) Comparison {
+	cmp := Comparison{Current: current, Previous: previous}
+	switch {
+	case previous == 0 && current == 0:
+		cmp.Status = "unchanged"
+		zero := 0.0
+		cmp.ChangePct = &zero
+	case previous == 0:
+		cmp.Status = "new"
+	default:
+		pct := math.Round(float64(current-previous)/float64(previous)*10000) / 100
+		cmp.ChangePct = &pct
+		switch {
+		case current > previous:
+			cmp.Status = "up"
+		case current < previous:
+			cmp.Status = "down"
+		default:
+			cmp.Status = "unchanged"
+		}
+	}
+	return cmp
+}
+
+// getMonthCompare compares a month (default the current one) with the month
+// before it: overall income and spending, and spending per category.
+// ?cleared_only=true leaves pending transactions out of all of them.
+func getMonthCompare(c *gin.Context) {
+	month, err := monthParam(c, "month")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, _ := time.Parse("2006-01", month)
+	previousMonth := m.AddDate(0, -1, 0).Format("2006-01")
+
+	clearedOnly := c.Query("cleared_only") == "true"
+	summaries, err := queryMonthlySummaries(previousMonth, month, 0, clearedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var current, previous MonthlySummary
+	for _, s := range summaries {
+		if s.Month == month {
+			current = s
+		} else {
+			previous = s
+		}
+	}
+
+	currentSpent, err := spentByCategory(month, clearedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	previousSpent, err := spentByCategory(previousMonth, clearedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories := []Comparison{}
+	seen := map[string]bool{}
+	for _, spent := range []map[string]Money{currentSpent, previousSpent} {
+		for category := range spent {
+			if seen[category] {
+				continue
+			}
+			seen[category] = true
+			cmp := compareAmounts(currentSpent[category], previousSpent[category])
+			cmp.Category = category
+			categories = append(categories, cmp)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Current != categories[j].Current {
+			return categories[i].Current > categories[j].Current
+		}
+		return categories[i].Category < categories[j].Category
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":          month,
+		"previous_month": previousMonth,
+		"expense":        compareAmounts(current.TotalExpense, previous.TotalExpense),
+		"income":         compareAmounts(current.TotalIncome, previous.TotalIncome),
+		"categories":     categories,
+	})
+}
+
+// getAvailableMonths lists the YYYY-MM months that have at least one
+// transaction, newest first, for month pickers.
+func getAvailableMonths(c *gin.Context) {
+	rows, err := db.Query(`
+		SELECT DISTINCT strftime('%Y-%m', datetime(date, ?)) AS month
+		FROM transactions
+		ORDER BY month DESC
+	`, tzModifier())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	months := []string{}
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		months = append(months, month)
+	}
+
+	c.JSON(http.StatusOK, months)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestMonthlySummaryTimezone checks that a late-evening transaction in a
+// zone behind UTC is counted in its local month, not the UTC one.
+func TestMonthlySummaryTimezone(t *testing.T) {
+	newTestDB(t)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+	previous := cfg.Timezone
+	cfg.Timezone = loc
+	t.Cleanup(func() { cfg.Timezone = previous })
+
+	date := time.Date(2024, time.January, 31, 23, 30, 0, 0, loc)
+	mustInsert(t, Transaction{Date: date.UTC(), Amount: -4200, Category: "Dining", Type: TypeExpense})
+
+	if got := localMonth(date); got != "2024-01" {
+		t.Errorf("localMonth = %s, want 2024-01", got)
+	}
+	summaries, err := queryMonthlySummaries("", "", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Month != "2024-01" || summaries[0].TotalExpense != 4200 {
+		t.Errorf("got %+v, want a single 2024-01 month with 42.00 spent", summaries)
+	}
+}
+
+// TestCategoryAverages checks that reimbursed expenses are left out and
+// that include_archived brings archived ones in.
+func TestCategoryAverages(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Now().AddDate(-5, 0, 0), Amount: -2000, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: time.Now(), Amount: -1000, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: time.Now(), Amount: -3000, Category: "Food", Type: TypeExpense})
+	if _, err := archiveOlderThan(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE transactions SET reimbursed = 1 WHERE id = 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		query   string
+		count   int
+		average Money
+	}{
+		{"", 1, 1000},
+		{"?include_archived=true", 2, 1500},
+	} {
+		w := serve(http.MethodGet, "/api/summary/averages", "/api/summary/averages"+tt.query, "", "", getCategoryAverages)
+		var got []CategoryAverage
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%d: %s", w.Code, w.Body)
+		}
+		if len(got) != 1 || got[0].Count != tt.count || got[0].Average != tt.average {
+			t.Errorf("%q: got %+v, want one category with count %d, average %s", tt.query, got, tt.count, tt.average)
+		}
+	}
+}
+
+// TestMonthCompareClearedOnly checks that cleared_only applies to the
+// per-category breakdown as well as the totals, so the two agree.
+func TestMonthCompareClearedOnly(t *testing.T) {
+	newTestDB(t)
+	date := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	mustInsert(t, Transaction{Date: date, Amount: -1000, Category: "Food", Type: TypeExpense})
+	mustInsert(t, Transaction{Date: date, Amount: -500, Category: "Food", Type: TypeExpense, Status: StatusPending})
+
+	w := serve(http.MethodGet, "/api/summary/month-compare", "/api/summary/month-compare?month=2024-03&cleared_only=true", "", "", getMonthCompare)
+	var got struct {
+		Expense    Comparison   `json:"expense"`
+		Categories []Comparison `json:"categories"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("%d: %s", w.Code, w.Body)
+	}
+	if got.Expense.Current != 1000 {
+		t.Errorf("expense = %s, want 10.00", got.Expense.Current)
+	}
+	if len(got.Categories) != 1 || got.Categories[0].Current != 1000 {
+		t.Errorf("got categories %+v, want Food at 10.00", got.Categories)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTagLength bounds a single tag.
+const maxTagLength = 64
+
+type tagByFilterRequest struct {
+	Filter transactionFilter `json:"filter"`
+	Tag    string            `json:"tag"`
+}
+
+// tagByFilter adds a tag, such as "reimbursable", to every transaction
+// matching the filter (from, to, category, type, q, ...) and answers with
+// the number newly tagged; transactions that already had it are skipped.
+// Nothing is tagged if any matching transaction is in a closed period.
+func tagByFilter(c *gin.Context) {
+	var req tagByFilterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Filter.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tag, err := normalizeTag(req.Tag)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": ValidationError{"tag": err.Error()}})
+		return
+	}
+
+	where, args := req.Filter.where()
+	if err := checkNoClosedRows("transactions", where, args...); err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+	result, err := db.Exec(
+		"INSERT OR IGNORE INTO transaction_tags (transaction_id, tag) SELECT id, ? FROM transactions "+where,
+		append([]any{tag}, args...)...,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagged, _ := result.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"tag": tag, "tagged": tagged})
+}
+
+// normalizeTag trims and lowercases a tag. Commas are rejected since tags
+// are read back as a comma-separated list.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case tag == "":
+		return "", errors.New("is required")
+	case len(tag) > maxTagLength:
+		return "", errors.New("must be at most 64 characters")
+	case strings.Contains(tag, ","):
+		return "", errors.New("must not contain commas")
+	}
+	return tag, nil
+}
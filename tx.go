@@ -0,0 +1,27 @@
+package main
+
+import "database/sql"
+
+// withTx runs fn in a database transaction. The transaction commits if fn
+// returns nil and rolls back if it returns an error or panics; a panic is
+// re-raised afterwards so gin's recovery still answers 500. Handlers that
+// change more than one row should do so through withTx and write their
+// response only once it returns.
+func withTx(fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+	return fn(tx)
+}
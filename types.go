@@ -0,0 +1,57 @@
+package main
+
+import "strings"
+
+// Transaction types. These are the only strings that may appear in the
+// type column; refer to them by constant rather than by literal.
+const (
+	TypeIncome  = "income"
+	TypeExpense = "expense"
+
+	// TypeRefund is money returned against an expense category, such as a
+	// returned purchase. Refunds are stored as positive amounts and net
+	// against their category's spending instead of counting as income:
+	// they lower total_expense, and so raise savings, while total_income
+	// is unchanged.
+	TypeRefund = "refund"
+)
+
+// transactionTypes is the set of accepted transaction types. To support a
+// new type such as "transfer", add a constant above and list it here.
+var transactionTypes = []string{TypeIncome, TypeExpense, TypeRefund}
+
+func isTransactionType(s string) bool {
+	for _, t := range transactionTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionTypeError is the validation message for an unknown type.
+func transactionTypeError() string {
+	return "must be one of " + strings.Join(transactionTypes, ", ")
+}
+
+// Transaction statuses. Pending transactions haven't yet been reconciled
+// with the bank.
+const (
+	StatusPending = "pending"
+	StatusCleared = "cleared"
+)
+
+func isTransactionStatus(s string) bool {
+	return s == StatusPending || s == StatusCleared
+}
+
+// Transaction contexts, which separate business spending, such as a
+// freelancer's deductible expenses, from personal spending.
+const (
+	ContextPersonal = "personal"
+	ContextBusiness = "business"
+)
+
+func isTransactionContext(s string) bool {
+	return s == ContextPersonal || s == ContextBusiness
+}
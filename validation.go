@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// ValidationError maps field names to what is wrong with them. Handlers
+// answer it with 422 Unprocessable Entity, reserving 400 for requests that
+// can't be parsed at all.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f + ": " + e[f]
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// err returns e as an error, or nil when no field failed.
+func (e ValidationError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestNegotiateExportXLSX checks that Accept picks the workbook, that the
+// sheet is well-formed with a numeric amount cell, and that an Accept
+// matching no format gets 406.
+func TestNegotiateExportXLSX(t *testing.T) {
+	newTestDB(t)
+	mustInsert(t, Transaction{Date: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Amount: -1234, Category: "Food", Description: "Fish & chips", Type: TypeExpense})
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/api/transactions/export", negotiateExport)
+		req := httptest.NewRequest(http.MethodGet, "/api/transactions/export", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(mimeXLSX)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != mimeXLSX {
+		t.Fatalf("got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("missing part %s", name)
+			continue
+		}
+		d := xml.NewDecoder(strings.NewReader(body))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s: %v", name, err)
+				break
+			}
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{`<c r="C2"><v>-12.34</v></c>`, "Fish &amp; chips"} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s:\n%s", want, sheet)
+		}
+	}
+
+	if w := get("image/png"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("Accept image/png: got %d, want 406", w.Code)
+	}
+}